@@ -0,0 +1,139 @@
+package rt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+type archiveFile struct {
+	io.Writer
+	file   io.Closer
+	bucket time.Time
+}
+
+// ArchiveWriter writes packets in the files of an archive organized according
+// to a Layout (DefaultLayout if none is given). At most keep files are left
+// open at any time so that late packets can still be appended to their file
+// without reopening it; when a new file is needed, the file with the oldest
+// time is closed first. An ArchiveWriter can be used by several goroutines.
+type ArchiveWriter struct {
+	mu     sync.Mutex
+	base   string
+	keep   int
+	layout *Layout
+	files  map[string]*archiveFile
+
+	codec string
+	level int
+}
+
+func NewArchiveWriter(base string, layout *Layout, keep int) (*ArchiveWriter, error) {
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return nil, err
+	}
+	if keep <= 0 {
+		keep = 2
+	}
+	if layout == nil {
+		layout = DefaultLayout
+	}
+	a := ArchiveWriter{
+		base:   base,
+		keep:   keep,
+		layout: layout,
+		files:  make(map[string]*archiveFile),
+	}
+	return &a, nil
+}
+
+// SetCompression makes the ArchiveWriter compress each file of the archive
+// independently with the codec registered for ext (eg: .gz or .zst) and the
+// given level. Files are then named after the layout with ext appended.
+func (a *ArchiveWriter) SetCompression(ext string, level int) error {
+	if _, ok := compressor(ext); ext != "" && !ok {
+		return fmt.Errorf("archive: no codec registered for %s", ext)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.codec, a.level = ext, level
+	return nil
+}
+
+// WritePacket writes the payload of a packet, framed as with NewWriter, in the
+// file of the archive given by the layout for i.
+func (a *ArchiveWriter) WritePacket(i PacketInfo, bs []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var (
+		p   = a.layout.Path(a.base, i) + a.codec
+		err error
+	)
+	f, ok := a.files[p]
+	if !ok {
+		if f, err = a.open(p, a.layout.Scale.ToUTC(i.When).Truncate(a.layout.Interval)); err != nil {
+			return err
+		}
+	}
+	_, err = f.Write(bs)
+	return err
+}
+
+func (a *ArchiveWriter) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var err error
+	for p, f := range a.files {
+		if e := f.file.Close(); e != nil {
+			err = e
+		}
+		delete(a.files, p)
+	}
+	return err
+}
+
+func (a *ArchiveWriter) open(p string, bucket time.Time) (*archiveFile, error) {
+	if len(a.files) >= a.keep {
+		if err := a.rotate(len(a.files) - a.keep + 1); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return nil, err
+	}
+	w, err := openWriter(p, os.O_CREATE|os.O_WRONLY|os.O_APPEND, a.level)
+	if err != nil {
+		return nil, err
+	}
+	f := archiveFile{
+		Writer: NewWriter(w),
+		file:   w,
+		bucket: bucket,
+	}
+	a.files[p] = &f
+	return &f, nil
+}
+
+func (a *ArchiveWriter) rotate(n int) error {
+	ps := make([]string, 0, len(a.files))
+	for p := range a.files {
+		ps = append(ps, p)
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		return a.files[ps[i]].bucket.Before(a.files[ps[j]].bucket)
+	})
+	var err error
+	for _, p := range ps[:n] {
+		if e := a.files[p].file.Close(); e != nil {
+			err = e
+		}
+		delete(a.files, p)
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package rt
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveWriter(t *testing.T) {
+	var (
+		when = time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+		// times in minutes after when; the packets at 1 and 7 are late and
+		// go to files already closed
+		minutes = []int{0, 2, 6, 11, 1, 12, 7, 16}
+		layout  = *DefaultLayout
+	)
+	layout.Scale = ScaleGPS
+	for _, codec := range []string{"", ".gz"} {
+		t.Run("codec"+codec, func(t *testing.T) {
+			base := t.TempDir()
+			a, err := NewArchiveWriter(base, &layout, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := a.SetCompression(codec, 0); err != nil {
+				t.Fatal(err)
+			}
+			var (
+				want  = make(map[string][]string)
+				files []string
+			)
+			for _, m := range minutes {
+				var (
+					i = PacketInfo{When: when.Add(time.Duration(m) * time.Minute)}
+					p = fmt.Sprintf("packet-%02d", m)
+					f = layout.Path(base, i) + codec
+				)
+				if err := a.WritePacket(i, []byte(p)); err != nil {
+					t.Fatal(err)
+				}
+				if n := len(a.files); n > 2 {
+					t.Errorf("packet %s: %d files open", p, n)
+				}
+				if _, ok := want[f]; !ok {
+					files = append(files, f)
+				}
+				want[f] = append(want[f], p)
+			}
+			if err := a.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := Browse([]string{base}, true, WithLayout(&layout))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			var (
+				got = make(map[string][]string)
+				sc  = NewScanner(r)
+			)
+			for sc.Scan() {
+				p := sc.Packet()
+				got[p.File] = append(got[p.File], string(p.Payload))
+			}
+			if err := sc.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(files) {
+				t.Errorf("got %d files, want %d", len(got), len(files))
+			}
+			for _, f := range files {
+				if fmt.Sprint(got[f]) != fmt.Sprint(want[f]) {
+					t.Errorf("%s: got %q, want %q", filepath.Base(f), got[f], want[f])
+				}
+			}
+		})
+	}
+}
+
+func TestArchiveWriterEviction(t *testing.T) {
+	layout := *DefaultLayout
+	layout.Scale = ScaleGPS
+	a, err := NewArchiveWriter(t.TempDir(), &layout, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	// 12:05:10 GPS is still 12:04:52 UTC: the first packet goes to the file
+	// of 12:00 and this file should be the first one closed
+	var (
+		when  = time.Date(2020, 5, 1, 12, 5, 10, 0, time.UTC)
+		first = layout.Path(a.base, PacketInfo{When: when})
+	)
+	for _, d := range []time.Duration{0, 20 * time.Second, 5*time.Minute + 20*time.Second} {
+		if err := a.WritePacket(PacketInfo{When: when.Add(d)}, []byte("packet")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := a.files[first]; ok {
+		t.Errorf("%s: still open", filepath.Base(first))
+	}
+}
@@ -0,0 +1,395 @@
+package rt
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BrowseRange is like Browse but only visits the directories and files of the
+// archive rooted at base (organized according to l) that overlap the interval
+// [starts, ends), given in the time scale of l. A zero time leaves the
+// corresponding side of the interval open.
+//
+// Files only give a coarse selection; when d is not nil, the packets of the
+// files that are not entirely in the interval are also filtered by their time
+// in the stream itself, whatever reads it. Packets that d can not decode are
+// kept, so are the packets following a length prefix that can not be trusted
+// in such a file (see Reader for resynchronizing). Offsets given by a Scanner
+// stay relative to the start of the files.
+func BrowseRange(base string, l *Layout, starts, ends time.Time, d HeaderDecoder, options ...BrowseOption) (io.ReadCloser, error) {
+	if l == nil {
+		l = DefaultLayout
+	}
+	cfg := newBrowseConfig(append([]BrowseOption{WithLayout(l)}, options...))
+	if d != nil {
+		cfg.filter = matchRange(d, starts, ends)
+	}
+	r, err := openBrowser(cfg, func(ctx context.Context) <-chan entry {
+		return walkRange(ctx, base, starts, ends, cfg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// matchRange accepts the packets whose time is in [starts, ends) and the
+// packets that d can not decode.
+func matchRange(d HeaderDecoder, starts, ends time.Time) MatchFunc {
+	return func(bs []byte) bool {
+		i, err := d.Decode(bs)
+		if err != nil {
+			return true
+		}
+		if !starts.IsZero() && i.When.Before(starts) {
+			return false
+		}
+		return ends.IsZero() || i.When.Before(ends)
+	}
+}
+
+// filterFrames makes f only give its frames whose payload matches fn.
+func (f *File) filterFrames(fn MatchFunc) {
+	f.frames = &frameFilter{
+		Reader: f.Reader,
+		match:  fn,
+		given:  f.pos,
+	}
+	f.Reader = f.frames
+}
+
+// frameFilter gives the frames of a stream whose payload matches. Once a
+// length prefix is greater than MaxPacketSize or a frame is truncated, the
+// rest of the stream is given as is.
+type frameFilter struct {
+	io.Reader
+	match MatchFunc
+
+	frame []byte
+	ready []byte
+	raw   bool
+	given int64
+	drops []drop
+}
+
+// drop gives the number of bytes dropped before the byte at pos of the
+// filtered stream.
+type drop struct {
+	pos   int64
+	total int64
+}
+
+func (f *frameFilter) Read(xs []byte) (int, error) {
+	for len(f.ready) == 0 {
+		if f.raw {
+			n, err := f.Reader.Read(xs)
+			f.given += int64(n)
+			return n, err
+		}
+		if err := f.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(xs, f.ready)
+	f.ready = f.ready[n:]
+	f.given += int64(n)
+	return n, nil
+}
+
+// next reads the next frame of the stream and makes it ready to be given if
+// its payload matches.
+func (f *frameFilter) next() error {
+	if cap(f.frame) < 4 {
+		f.frame = make([]byte, 4, 4096)
+	}
+	head := f.frame[:4]
+	if n, err := io.ReadFull(f.Reader, head); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			f.ready, f.raw = head[:n], true
+			return nil
+		}
+		return err
+	}
+	size := int(binary.LittleEndian.Uint32(head))
+	if size > MaxPacketSize {
+		f.ready, f.raw = head, true
+		return nil
+	}
+	if cap(f.frame) < size+4 {
+		f.frame = make([]byte, size+4)
+		copy(f.frame, head)
+	}
+	frame := f.frame[:size+4]
+	if n, err := io.ReadFull(f.Reader, frame[4:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			f.ready, f.raw = frame[:n+4], true
+			return nil
+		}
+		return err
+	}
+	if f.match(frame[4:]) {
+		f.ready = frame
+		return nil
+	}
+	total := int64(len(frame))
+	if n := len(f.drops); n > 0 {
+		total += f.drops[n-1].total
+		if f.drops[n-1].pos == f.given {
+			f.drops[n-1].total = total
+			return nil
+		}
+	}
+	f.drops = append(f.drops, drop{pos: f.given, total: total})
+	return nil
+}
+
+// offsetOf gives the position in the stream of the byte at pos of the
+// filtered stream.
+func (f *frameFilter) offsetOf(pos int64) int64 {
+	i := sort.Search(len(f.drops), func(i int) bool {
+		return f.drops[i].pos > pos
+	})
+	if i == 0 {
+		return pos
+	}
+	return pos + f.drops[i-1].total
+}
+
+func walkRange(ctx context.Context, base string, starts, ends time.Time, cfg browseConfig) <-chan entry {
+	var (
+		q      = make(chan entry)
+		l      = cfg.layout
+		parts  = strings.Split(l.Pattern, "/")
+		prefix = make([]Formatter, len(parts)-1)
+	)
+	for i := range prefix {
+		prefix[i], _ = Parse(strings.Join(parts[:i+1], "/"))
+	}
+	go func() {
+		defer close(q)
+		c := collector{ctx: ctx, q: q, cfg: cfg}
+		walkDir(cfg.fsys, base, func(p string, i fs.DirEntry, err error) error {
+			if e := ctx.Err(); e != nil {
+				return e
+			}
+			if err != nil {
+				return c.add(entry{file: p, err: err})
+			}
+			rel, err := filepath.Rel(base, p)
+			if err != nil || rel == "." {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			if i.IsDir() {
+				depth := strings.Count(rel, "/")
+				if depth >= len(prefix) || prefix[depth] == nil {
+					return fs.SkipDir
+				}
+				s, e, err := ExtractInterval(prefix[depth], rel)
+				if err != nil || !overlap(l.fromUTC(s), l.fromUTC(e), starts, ends) {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			s, e, err := l.Span(base, p)
+			if err == nil && overlap(s, e, starts, ends) {
+				return c.add(entry{file: p, partial: !within(s, e, starts, ends)})
+			}
+			return nil
+		})
+		if ctx.Err() == nil {
+			c.flush()
+		}
+	}()
+	return q
+}
+
+// overlap reports whether [s, e) overlaps [starts, ends). A zero s means the
+// interval is unknown and always overlaps.
+func overlap(s, e, starts, ends time.Time) bool {
+	if s.IsZero() {
+		return true
+	}
+	if !ends.IsZero() && !s.Before(ends) {
+		return false
+	}
+	return starts.IsZero() || e.After(starts)
+}
+
+// within reports whether [s, e) is entirely in [starts, ends). A zero s means
+// the interval is unknown and is never within.
+func within(s, e, starts, ends time.Time) bool {
+	if s.IsZero() {
+		return false
+	}
+	if !starts.IsZero() && s.Before(starts) {
+		return false
+	}
+	return ends.IsZero() || !e.After(ends)
+}
+
+type BrowseOption func(*browseConfig)
+
+// WithLayout sets the layout used to find the time of files in their path.
+func WithLayout(l *Layout) BrowseOption {
+	return func(c *browseConfig) {
+		if l != nil {
+			c.layout = l
+		}
+	}
+}
+
+// OrderBy sets the func giving the time used to sort files, eg: the time of
+// their first packet. When this func fails for a file, its time is taken from
+// its path.
+func OrderBy(fn func(string) (time.Time, error)) BrowseOption {
+	return func(c *browseConfig) {
+		c.order = fn
+	}
+}
+
+// Unordered keeps files in the order they are found. Files are then given as
+// soon as they are found, without waiting for the whole archive to be walked.
+func Unordered() BrowseOption {
+	return func(c *browseConfig) {
+		c.ordered = false
+	}
+}
+
+// WithContext stops walking the archive when ctx is cancelled. The goroutine
+// walking the archive is always stopped when the stream is closed.
+func WithContext(ctx context.Context) BrowseOption {
+	return func(c *browseConfig) {
+		if ctx != nil {
+			c.ctx = ctx
+		}
+	}
+}
+
+// SkipErrors makes the stream skip the files that can not be walked or opened
+// instead of failing. fn is called with each of these files and its error. A
+// Scanner reading the stream also gives fn the files it left because their
+// content is corrupted.
+func SkipErrors(fn func(string, error)) BrowseOption {
+	return func(c *browseConfig) {
+		if fn == nil {
+			fn = func(string, error) {}
+		}
+		c.skip = fn
+	}
+}
+
+// WithFS makes Browse and its variants look for files in fsys instead of the
+// OS filesystem, eg: an archive/zip Reader. Paths are then slash separated
+// and relative to the root of fsys (see fs.ValidPath).
+func WithFS(fsys fs.FS) BrowseOption {
+	return func(c *browseConfig) {
+		c.fsys = fsys
+	}
+}
+
+// StartAt makes the stream start with the first packet whose time is not
+// before t. Files with an up to date index (see IndexFile) are moved to this
+// packet or skipped when all their packets are before t; other files are given
+// whole.
+func StartAt(t time.Time) BrowseOption {
+	return func(c *browseConfig) {
+		c.from = t
+	}
+}
+
+type browseConfig struct {
+	fsys    fs.FS
+	from    time.Time
+	polling time.Duration
+	drain   time.Duration
+	layout  *Layout
+	ordered bool
+	order   func(string) (time.Time, error)
+
+	ctx    context.Context
+	skip   func(string, error)
+	filter MatchFunc
+}
+
+func newBrowseConfig(options []BrowseOption) browseConfig {
+	cfg := browseConfig{
+		layout:  DefaultLayout,
+		drain:   defaultDrain,
+		ordered: true,
+		ctx:     context.Background(),
+	}
+	for _, o := range options {
+		o(&cfg)
+	}
+	return cfg
+}
+
+// sort sorts files by time. The time of a file is given by the order func if
+// set, then by the layout applied to the last elements of its path and
+// finally by its modification time.
+func (c browseConfig) sort(files []entry) {
+	ts := make(map[string]time.Time, len(files))
+	for _, f := range files {
+		ts[f.file] = c.timeOf(f.file)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		ti, tj := ts[files[i].file], ts[files[j].file]
+		if ti.Equal(tj) {
+			return files[i].file < files[j].file
+		}
+		return ti.Before(tj)
+	})
+}
+
+func (c browseConfig) timeOf(file string) time.Time {
+	if c.order != nil {
+		if w, err := c.order(file); err == nil && !w.IsZero() {
+			return w
+		}
+	}
+	if c.layout != nil {
+		var (
+			n     = strings.Count(c.layout.Pattern, "/") + 1
+			parts = strings.Split(filepath.ToSlash(TrimCodec(file)), "/")
+		)
+		if len(parts) >= n {
+			w, _, err := ExtractInterval(c.layout.format, strings.Join(parts[len(parts)-n:], "/"))
+			if err == nil && !w.IsZero() {
+				return w
+			}
+		}
+	}
+	if i, err := c.stat(file); err == nil {
+		return i.ModTime()
+	}
+	return time.Time{}
+}
+
+// seek moves f to the first packet not before the time given with StartAt. It
+// returns io.EOF when f has no such packet.
+func (c browseConfig) seek(f *File) error {
+	if c.from.IsZero() {
+		return nil
+	}
+	x, err := loadIndex(c.fsys, f.Name())
+	if err != nil {
+		return nil
+	}
+	_, err = f.SeekTime(x, c.from)
+	return err
+}
+
+func (c browseConfig) stat(file string) (fs.FileInfo, error) {
+	if c.fsys == nil {
+		return os.Stat(file)
+	}
+	return fs.Stat(c.fsys, file)
+}
@@ -0,0 +1,114 @@
+package rt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBrowseRange(t *testing.T) {
+	var (
+		base = t.TempDir()
+		day  = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	)
+	packet := func(m int) []byte {
+		bs := make([]byte, 8)
+		binary.BigEndian.PutUint32(bs, uint32(day.Add(time.Duration(m)*time.Minute).Unix()))
+		return bs
+	}
+	data := []struct {
+		Minute  int
+		Packets [][]byte
+	}{
+		{Minute: 0, Packets: [][]byte{packet(1), []byte("x"), packet(3)}},
+		{Minute: 5, Packets: [][]byte{packet(6), packet(-60), packet(8)}},
+		{Minute: 10, Packets: [][]byte{packet(11), packet(13)}},
+		{Minute: 15, Packets: [][]byte{packet(16)}},
+	}
+	for _, d := range data {
+		p := DefaultLayout.Path(base, PacketInfo{When: day.Add(time.Duration(d.Minute) * time.Minute)})
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Create(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := NewWriter(f)
+		for _, bs := range d.Packets {
+			w.Write(bs)
+		}
+		f.Close()
+	}
+	dec, err := LookupDecoder("generic:time=0/4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	label := func(bs []byte) string {
+		if len(bs) < 4 {
+			return string(bs)
+		}
+		w := time.Unix(int64(binary.BigEndian.Uint32(bs)), 0).UTC()
+		return w.Format("15:04")
+	}
+	browse := func() io.ReadCloser {
+		r, err := BrowseRange(base, nil, day.Add(2*time.Minute), day.Add(12*time.Minute), dec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	compare := func(got, want []string) {
+		t.Helper()
+		if len(got) != len(want) {
+			t.Fatalf("got %q, want %q", got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("packet %d: got %s, want %s", i, got[i], want[i])
+			}
+		}
+	}
+
+	for _, options := range [][]ReaderOption{nil, {WithResync(0)}} {
+		r := browse()
+		defer r.Close()
+		var (
+			sc  = NewScanner(r, options...)
+			got []string
+		)
+		for sc.Scan() {
+			p := sc.Packet()
+			got = append(got, fmt.Sprintf("%s@%d", label(p.Payload), p.Offset))
+		}
+		if err := sc.Err(); err != nil {
+			t.Fatal(err)
+		}
+		compare(got, []string{"x@12", "12:03@17", "12:06@0", "11:00@12", "12:08@24", "12:11@0"})
+	}
+
+	// the filter does not depend on what reads the stream
+	r := browse()
+	defer r.Close()
+	var (
+		rs  = NewReader(bufio.NewReader(r))
+		buf = make([]byte, 64)
+		got []string
+	)
+	for {
+		n, err := rs.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, label(buf[4:n]))
+	}
+	compare(got, []string{"x", "12:03", "12:06", "11:00", "12:08", "12:11"})
+}
@@ -0,0 +1,108 @@
+package rt
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+)
+
+// BrowseTar gives a stream made of the .dat files of the tar archive read
+// from r, compressed or not. The archive is read only once, so files are
+// given in the order they are stored whatever the ordering options. Errors
+// of the archive itself always stop the stream.
+func BrowseTar(r io.Reader, options ...BrowseOption) (io.ReadCloser, error) {
+	var (
+		cfg         = newBrowseConfig(options)
+		tr          = tar.NewReader(r)
+		ctx, cancel = context.WithCancel(cfg.ctx)
+	)
+	next := func() (*File, error) {
+		for {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			h, err := nextTar(tr)
+			if err != nil {
+				return nil, err
+			}
+			f, err := newFile(ioutil.NopCloser(tr), h.Name)
+			if err == nil {
+				return f, nil
+			}
+			if cfg.skip == nil {
+				return nil, err
+			}
+			cfg.skip(h.Name, err)
+		}
+	}
+	m, err := newMultiReader(ctx, cancel, next, cfg.skip)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// nextTar advances tr to its next regular .dat file.
+func nextTar(tr *tar.Reader) (*tar.Header, error) {
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if h.FileInfo().Mode().IsRegular() && hasExt(h.Name, ".dat") {
+			return h, nil
+		}
+	}
+}
+
+// IsBundle reports whether file is a zip or a tar archive (compressed with a
+// registered codec or not) that can be given to BrowseBundle.
+func IsBundle(file string) bool {
+	switch filepath.Ext(TrimCodec(file)) {
+	case ".zip", ".tar":
+		return true
+	default:
+		return false
+	}
+}
+
+// BrowseBundle gives a stream made of the .dat files of a zip or tar archive.
+// Files of a zip archive are browsed like with Browse; files of a tar archive
+// are given in the order they are stored.
+func BrowseBundle(file string, options ...BrowseOption) (io.ReadCloser, error) {
+	switch filepath.Ext(TrimCodec(file)) {
+	case ".zip":
+		z, err := zip.OpenReader(file)
+		if err != nil {
+			return nil, err
+		}
+		cfg := newBrowseConfig(append(options, WithFS(z)))
+		r, err := openBrowser(cfg, func(ctx context.Context) <-chan entry {
+			return walk(ctx, []string{"."}, true, cfg)
+		})
+		if err != nil {
+			z.Close()
+			return nil, err
+		}
+		r.bundle = z
+		return r, nil
+	case ".tar":
+		f, err := Open(file)
+		if err != nil {
+			return nil, err
+		}
+		r, err := BrowseTar(f, options...)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		r.(*multiReader).bundle = f
+		return r, nil
+	default:
+		return nil, fmt.Errorf("bundle: %s: not a zip or tar archive", file)
+	}
+}
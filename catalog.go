@@ -0,0 +1,306 @@
+package rt
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Summary describes a file of an archive as recorded in a Catalog. The embedded
+// Coze gives the number of packets and their size, the bytes lost in the file
+// (Missing), the packets whose header could not be decoded (Error), the
+// sequence counters of the first and last packets and the earliest and latest
+// times of the packets.
+type Summary struct {
+	File    string    `json:"file"`
+	Length  int64     `json:"length"`
+	ModTime time.Time `json:"mtime"`
+	Sum     uint64    `json:"xxh64"`
+	Pids    []int     `json:"pids,omitempty"`
+	Err     string    `json:"err,omitempty"`
+	Deleted bool      `json:"deleted,omitempty"`
+	Coze
+}
+
+// UnmarshalJSON also accepts the keys used for the times of the packets in
+// the catalogs written before they were named dtstart and dtend.
+func (m *Summary) UnmarshalJSON(bs []byte) error {
+	type summary Summary
+	v := struct {
+		*summary
+		StartTime time.Time `json:"StartTime"`
+		EndTime   time.Time `json:"EndTime"`
+	}{
+		summary: (*summary)(m),
+	}
+	if err := json.Unmarshal(bs, &v); err != nil {
+		return err
+	}
+	if m.StartTime.IsZero() {
+		m.StartTime = v.StartTime
+	}
+	if m.EndTime.IsZero() {
+		m.EndTime = v.EndTime
+	}
+	return nil
+}
+
+// Summarize reads file and gives its Summary. When d is nil, the pids and the
+// times of the packets are not known.
+func Summarize(file string, d HeaderDecoder) (Summary, error) {
+	i, err := os.Stat(file)
+	if err != nil {
+		return Summary{}, err
+	}
+	return summarize(make([]byte, MaxPacketSize+4), file, i, d)
+}
+
+func summarize(buf []byte, file string, i fs.FileInfo, d HeaderDecoder) (Summary, error) {
+	var (
+		m     = Summary{File: file, Length: i.Size()}
+		pids  = make(map[int]struct{})
+		first = true
+	)
+	s, err := checkFile(buf, nil, file, i, func(bs []byte) {
+		if d == nil {
+			return
+		}
+		p, err := d.Decode(bs)
+		if err != nil {
+			m.Coze.Error++
+			return
+		}
+		pids[p.Pid] = struct{}{}
+		if first {
+			m.First, first = uint64(p.Sequence), false
+		}
+		m.Last = uint64(p.Sequence)
+		if p.When.IsZero() {
+			return
+		}
+		if m.StartTime.IsZero() || p.When.Before(m.StartTime) {
+			m.StartTime = p.When
+		}
+		if p.When.After(m.EndTime) {
+			m.EndTime = p.When
+		}
+	})
+	if err != nil {
+		return m, err
+	}
+	m.ModTime = s.LastMod
+	m.Sum = s.Sum
+	m.Count = uint64(s.Packets)
+	m.Size = uint64(s.Size)
+	m.Missing = uint64(s.Bytes - s.Size)
+	if s.Err != nil {
+		m.Err = s.Err.Error()
+	}
+	for p := range pids {
+		m.Pids = append(m.Pids, p)
+	}
+	sort.Ints(m.Pids)
+	return m, nil
+}
+
+// Catalog keeps the summaries of the files of an archive in a local file. The
+// file is only appended to: a file of the archive that changes gets a new
+// summary that replaces the previous one when the catalog is loaded.
+type Catalog struct {
+	file    string
+	decoder HeaderDecoder
+	files   map[string]Summary
+}
+
+// OpenCatalog loads the catalog stored in file, if any. The decoder is used to
+// summarize the files added to the catalog by Refresh. A last summary left
+// incomplete by a crash is removed from the file.
+func OpenCatalog(file string, d HeaderDecoder) (*Catalog, error) {
+	c := Catalog{
+		file:    file,
+		decoder: d,
+		files:   make(map[string]Summary),
+	}
+	r, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return &c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var (
+		rs   = bufio.NewReader(r)
+		line int
+		size int64
+	)
+	for {
+		bs, err := rs.ReadBytes('\n')
+		if len(bs) == 0 && err == io.EOF {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line++
+		var m Summary
+		if e := json.Unmarshal(bs, &m); e != nil || err == io.EOF {
+			if _, e := rs.Peek(1); e == io.EOF {
+				// the next summaries should not be appended to this line
+				if err := os.Truncate(file, size); err != nil {
+					return nil, err
+				}
+				break
+			}
+			return nil, fmt.Errorf("catalog: %s:%d: %w", file, line, e)
+		}
+		size += int64(len(bs))
+		if m.Deleted {
+			delete(c.files, m.File)
+		} else {
+			c.files[m.File] = m
+		}
+	}
+	return &c, nil
+}
+
+// Refresh walks the given roots and summarizes the .dat files that are not in
+// the catalog yet or that changed since they were summarized. Files of the
+// catalog under the roots that do not exist anymore are removed. It gives the
+// number of summaries added to the catalog.
+func (c *Catalog) Refresh(roots ...string) (int, error) {
+	w, err := os.OpenFile(c.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer w.Close()
+
+	var (
+		buf   = make([]byte, MaxPacketSize+4)
+		seen  = make(map[string]struct{})
+		ws    = json.NewEncoder(w)
+		count int
+	)
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(p string, e fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if e.IsDir() || !hasExt(p, ".dat") {
+				return nil
+			}
+			seen[p] = struct{}{}
+			i, err := e.Info()
+			if err != nil {
+				return err
+			}
+			if m, ok := c.files[p]; ok && m.Length == i.Size() && m.ModTime.Equal(i.ModTime()) {
+				return nil
+			}
+			m, err := summarize(buf, p, i, c.decoder)
+			if err != nil {
+				return err
+			}
+			if err := ws.Encode(m); err != nil {
+				return err
+			}
+			c.files[p] = m
+			count++
+			return nil
+		})
+		if err != nil {
+			return count, err
+		}
+	}
+	for p := range c.files {
+		if _, ok := seen[p]; ok || !under(p, roots) {
+			continue
+		}
+		if err := ws.Encode(Summary{File: p, Deleted: true}); err != nil {
+			return count, err
+		}
+		delete(c.files, p)
+		count++
+	}
+	return count, nil
+}
+
+// Lookup gives the summary of the given file.
+func (c *Catalog) Lookup(file string) (Summary, bool) {
+	m, ok := c.files[file]
+	return m, ok
+}
+
+// Files gives the summaries of all the files of the catalog sorted by time.
+func (c *Catalog) Files() []Summary {
+	return c.Query(time.Time{}, time.Time{})
+}
+
+// Query gives the summaries, sorted by time, of the files with packets in the
+// interval [starts, ends] and with at least one packet of the given pids. A
+// zero time leaves the corresponding side of the interval open. Files whose
+// pids or times are not known are always selected.
+func (c *Catalog) Query(starts, ends time.Time, pids ...int) []Summary {
+	var ms []Summary
+	for _, m := range c.files {
+		if m.match(starts, ends, pids) {
+			ms = append(ms, m)
+		}
+	}
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].StartTime.Equal(ms[j].StartTime) {
+			return ms[i].File < ms[j].File
+		}
+		return ms[i].StartTime.Before(ms[j].StartTime)
+	})
+	return ms
+}
+
+// Select is like Query but only gives the names of the files.
+func (c *Catalog) Select(starts, ends time.Time, pids ...int) []string {
+	ms := c.Query(starts, ends, pids...)
+	files := make([]string, len(ms))
+	for i, m := range ms {
+		files[i] = m.File
+	}
+	return files
+}
+
+func (m Summary) match(starts, ends time.Time, pids []int) bool {
+	if !m.StartTime.IsZero() {
+		if !ends.IsZero() && m.StartTime.After(ends) {
+			return false
+		}
+		if !starts.IsZero() && m.EndTime.Before(starts) {
+			return false
+		}
+	}
+	if len(pids) == 0 || len(m.Pids) == 0 {
+		return true
+	}
+	for _, p := range pids {
+		ix := sort.SearchInts(m.Pids, p)
+		if ix < len(m.Pids) && m.Pids[ix] == p {
+			return true
+		}
+	}
+	return false
+}
+
+func under(file string, roots []string) bool {
+	for _, r := range roots {
+		r = filepath.Clean(r)
+		if file == r || strings.HasPrefix(file, r+string(filepath.Separator)) || r == "." && !filepath.IsAbs(file) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package rt
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCatalogIncomplete(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		db   = filepath.Join(dir, "rt.catalog")
+		data = filepath.Join(dir, "archive", "rt_00_04.dat")
+	)
+	if err := os.MkdirAll(filepath.Dir(data), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(packets ...string) {
+		f, err := os.OpenFile(data, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := NewWriter(f)
+		for _, p := range packets {
+			w.Write([]byte(p))
+		}
+		f.Close()
+	}
+	refresh := func(count uint64) {
+		c, err := OpenCatalog(db, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Refresh(filepath.Dir(data)); err != nil {
+			t.Fatal(err)
+		}
+		if m, ok := c.Lookup(data); !ok || m.Count != count {
+			t.Fatalf("expected %d packets, got %d", count, m.Count)
+		}
+	}
+
+	write("alpha", "beta")
+	refresh(2)
+
+	f, err := os.OpenFile(db, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"file":"` + data + `","len`)
+	f.Close()
+
+	for i, p := range []string{"gamma", "delta"} {
+		write(p)
+		refresh(uint64(3 + i))
+	}
+	c, err := OpenCatalog(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := c.Lookup(data); !ok || m.Count != 4 {
+		t.Errorf("expected 4 packets, got %d", m.Count)
+	}
+}
+
+func TestSummaryKeys(t *testing.T) {
+	data := []string{
+		`{"file":"rt_00_04.dat","length":12,"xxh64":1,"pids":[2],"First":1,"Last":2,"StartTime":"2021-06-01T12:00:00Z","EndTime":"2021-06-01T12:04:00Z","count":2}`,
+		`{"file":"rt_00_04.dat","length":12,"xxh64":1,"pids":[2],"first":1,"last":2,"dtstart":"2021-06-01T12:00:00Z","dtend":"2021-06-01T12:04:00Z","count":2}`,
+	}
+	var (
+		starts = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+		ends   = starts.Add(4 * time.Minute)
+	)
+	for _, d := range data {
+		var m Summary
+		if err := json.Unmarshal([]byte(d), &m); err != nil {
+			t.Errorf("%s: %v", d, err)
+			continue
+		}
+		if m.File != "rt_00_04.dat" || m.Count != 2 || m.First != 1 || m.Last != 2 || len(m.Pids) != 1 {
+			t.Errorf("%s: unexpected summary %+v", d, m)
+		}
+		if !m.StartTime.Equal(starts) || !m.EndTime.Equal(ends) {
+			t.Errorf("%s: expected %s-%s, got %s-%s", d, starts, ends, m.StartTime, m.EndTime)
+		}
+	}
+}
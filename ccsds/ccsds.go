@@ -0,0 +1,239 @@
+package ccsds
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/busoc/rt"
+)
+
+const HeaderLen = 6
+
+var ErrShort = errors.New("ccsds: packet too short")
+
+type LengthError struct {
+	Want int
+	Got  int
+}
+
+func (e LengthError) Error() string {
+	return fmt.Sprintf("ccsds: length mismatch (header: %d, rt: %d)", e.Want, e.Got)
+}
+
+type PacketType uint8
+
+const (
+	Telemetry PacketType = iota
+	Telecommand
+)
+
+func (t PacketType) String() string {
+	if t == Telecommand {
+		return "tc"
+	}
+	return "tm"
+}
+
+type SegmentFlag uint8
+
+const (
+	Continuation SegmentFlag = iota
+	First
+	Last
+	Unsegmented
+)
+
+func (s SegmentFlag) String() string {
+	switch s {
+	case Continuation:
+		return "continuation"
+	case First:
+		return "first"
+	case Last:
+		return "last"
+	default:
+		return "unsegmented"
+	}
+}
+
+func (s SegmentFlag) Segment() rt.Segment {
+	switch s {
+	case Continuation:
+		return rt.Continuation
+	case First:
+		return rt.First
+	case Last:
+		return rt.Last
+	default:
+		return rt.Unsegmented
+	}
+}
+
+// Header is the primary header of a CCSDS space packet.
+type Header struct {
+	Version   uint8
+	Type      PacketType
+	Secondary bool
+	Apid      uint16
+	Segment   SegmentFlag
+	Sequence  uint16
+	Length    uint16
+}
+
+// Len gives the size of the packet, primary header included, as announced by
+// the data length field of the header.
+func (h Header) Len() int {
+	return HeaderLen + int(h.Length) + 1
+}
+
+func DecodeHeader(bs []byte) (Header, error) {
+	var h Header
+	if len(bs) < HeaderLen {
+		return h, ErrShort
+	}
+	id := binary.BigEndian.Uint16(bs)
+	seq := binary.BigEndian.Uint16(bs[2:])
+
+	h.Version = uint8(id >> 13)
+	h.Type = PacketType((id >> 12) & 0x1)
+	h.Secondary = (id>>11)&0x1 == 1
+	h.Apid = id & 0x7FF
+	h.Segment = SegmentFlag(seq >> 14)
+	h.Sequence = seq & 0x3FFF
+	h.Length = binary.BigEndian.Uint16(bs[4:])
+	return h, nil
+}
+
+// Check decodes the primary header of the payload of an rt frame and verifies
+// that the length it announces matches the length of the payload.
+func Check(bs []byte) (Header, error) {
+	h, err := DecodeHeader(bs)
+	if err != nil {
+		return h, err
+	}
+	if n := h.Len(); n != len(bs) {
+		return h, LengthError{Want: n, Got: len(bs)}
+	}
+	return h, nil
+}
+
+// Offset can be given to rt.MergeFiles and rt.NewMerger. It receives full rt
+// frames, length prefix included.
+func Offset(bs []byte) (rt.Offset, error) {
+	var o rt.Offset
+	if len(bs) < 4 {
+		return o, rt.ErrInvalid
+	}
+	h, err := Check(bs[4:])
+	if err != nil {
+		return o, err
+	}
+	o.Pid = uint(h.Apid)
+	o.Sequence = uint(h.Sequence)
+	o.Len = uint(len(bs) - 4)
+	return o, nil
+}
+
+// Decoder decodes the primary header of CCSDS packets. When Time is set, the
+// time of packets having a secondary header is decoded from the time code that
+// starts Offset bytes after the primary header.
+type Decoder struct {
+	Time   TimeDecoder
+	Offset int
+}
+
+// CounterBits gives the width of the sequence counter of the primary header.
+func (d Decoder) CounterBits() int {
+	return 14
+}
+
+func (d Decoder) Decode(bs []byte) (rt.PacketInfo, error) {
+	var i rt.PacketInfo
+	h, err := Check(bs)
+	if err != nil {
+		return i, err
+	}
+	i.Pid = int(h.Apid)
+	i.Sequence = uint(h.Sequence)
+	i.Segment = h.Segment.Segment()
+	if h.Secondary && d.Time != nil {
+		if HeaderLen+d.Offset > len(bs) {
+			return i, ErrShort
+		}
+		if i.When, _, err = d.Time.DecodeTime(bs[HeaderLen+d.Offset:]); err != nil {
+			return i, err
+		}
+	}
+	return i, nil
+}
+
+func init() {
+	rt.RegisterDecoder("ccsds", parseDecoder)
+}
+
+// parseDecoder parses a comma separated list of options:
+//
+//	time=cuc|cds  time code in the secondary header
+//	offset=N      position of the time code in the secondary header
+//	epoch=E       tai, gps, unix or a RFC3339 time
+//	coarse=N      coarse octets of CUC
+//	fine=N        fine octets of CUC
+//	days=N        octets of the day segment of CDS
+//	sub=N         octets of the sub-millisecond segment of CDS
+//	pfield        the time code starts with its P-field
+func parseDecoder(args string) (rt.HeaderDecoder, error) {
+	var (
+		d      Decoder
+		code   string
+		epoch  time.Time
+		pfield bool
+		vs     = make(map[string]int)
+	)
+	if args == "" {
+		return d, nil
+	}
+	for _, a := range strings.Split(args, ",") {
+		var (
+			name  = a
+			value string
+		)
+		if ix := strings.IndexByte(a, '='); ix >= 0 {
+			name, value = a[:ix], a[ix+1:]
+		}
+		var err error
+		switch name {
+		case "time":
+			code = value
+		case "epoch":
+			epoch, err = ParseEpoch(value)
+		case "pfield":
+			pfield = true
+		case "offset", "coarse", "fine", "days", "sub":
+			vs[name], err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("ccsds: unknown option %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	d.Offset = vs["offset"]
+	switch code {
+	case "cuc":
+		coarse := vs["coarse"]
+		if coarse == 0 {
+			coarse = 4
+		}
+		d.Time = CUC{Epoch: epoch, Coarse: coarse, Fine: vs["fine"], PField: pfield}
+	case "cds":
+		d.Time = CDS{Epoch: epoch, Days: vs["days"], Sub: vs["sub"], PField: pfield}
+	case "":
+	default:
+		return nil, fmt.Errorf("ccsds: unknown time code %s", code)
+	}
+	return d, nil
+}
@@ -0,0 +1,196 @@
+package ccsds
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/busoc/rt"
+)
+
+var (
+	TAI = time.Date(1958, 1, 1, 0, 0, 0, 0, time.UTC)
+	GPS = rt.GPS
+)
+
+var ErrTimeCode = errors.New("ccsds: invalid time code")
+
+const (
+	cucLevel1 = 1
+	cucLevel2 = 2
+	cdsCode   = 4
+)
+
+// TimeDecoder decodes a time code at the start of the given bytes and returns
+// the time and the number of bytes used by the code, P-field included.
+//
+// Times are given as the elapsed time since the epoch of the code, without any
+// correction for leap seconds.
+type TimeDecoder interface {
+	DecodeTime([]byte) (time.Time, int, error)
+}
+
+// CUC decodes CCSDS Unsegmented time codes. When PField is set, the number of
+// coarse and fine octets is read from the P-field preceding the T-field and a
+// level 1 code always uses the TAI epoch.
+type CUC struct {
+	Epoch  time.Time
+	Coarse int
+	Fine   int
+	PField bool
+}
+
+func (c CUC) DecodeTime(bs []byte) (time.Time, int, error) {
+	var (
+		epoch  = c.Epoch
+		coarse = c.Coarse
+		fine   = c.Fine
+		offset int
+	)
+	if c.PField {
+		if len(bs) < 1 {
+			return time.Time{}, 0, ErrShort
+		}
+		p := bs[0]
+		offset++
+		switch (p >> 4) & 0x7 {
+		case cucLevel1:
+			epoch = TAI
+		case cucLevel2:
+		default:
+			return time.Time{}, 0, ErrTimeCode
+		}
+		coarse = int((p>>2)&0x3) + 1
+		fine = int(p & 0x3)
+		if p>>7 == 1 {
+			if len(bs) < 2 {
+				return time.Time{}, 0, ErrShort
+			}
+			e := bs[1]
+			offset++
+			coarse += int((e >> 5) & 0x3)
+			fine += int((e >> 2) & 0x7)
+		}
+	}
+	if coarse <= 0 || coarse > 7 || fine < 0 || fine > 10 {
+		return time.Time{}, 0, ErrTimeCode
+	}
+	if epoch.IsZero() {
+		epoch = TAI
+	}
+	if len(bs) < offset+coarse+fine {
+		return time.Time{}, 0, ErrShort
+	}
+	secs := readUint(bs[offset : offset+coarse])
+	offset += coarse
+
+	var nanos uint64
+	if fine > 0 {
+		n := fine
+		if n > 4 {
+			n = 4
+		}
+		frac := readUint(bs[offset : offset+n])
+		nanos = (frac * uint64(time.Second)) >> (8 * uint(n))
+	}
+	offset += fine
+
+	w := epoch.Add(time.Duration(secs) * time.Second).Add(time.Duration(nanos))
+	return w, offset, nil
+}
+
+// CDS decodes CCSDS Day Segmented time codes. Days is the size of the day
+// segment (2 or 3 bytes) and Sub the size of the sub-millisecond segment (0, 2
+// for microseconds or 4 for picoseconds). When PField is set, these values are
+// read from the P-field and the TAI epoch is used unless the P-field selects
+// an agency defined epoch.
+type CDS struct {
+	Epoch  time.Time
+	Days   int
+	Sub    int
+	PField bool
+}
+
+func (c CDS) DecodeTime(bs []byte) (time.Time, int, error) {
+	var (
+		epoch  = c.Epoch
+		days   = c.Days
+		sub    = c.Sub
+		offset int
+	)
+	if c.PField {
+		if len(bs) < 1 {
+			return time.Time{}, 0, ErrShort
+		}
+		p := bs[0]
+		offset++
+		if (p>>4)&0x7 != cdsCode {
+			return time.Time{}, 0, ErrTimeCode
+		}
+		if (p>>3)&0x1 == 0 {
+			epoch = TAI
+		}
+		days = 2 + int((p>>2)&0x1)
+		switch p & 0x3 {
+		case 0:
+			sub = 0
+		case 1:
+			sub = 2
+		case 2:
+			sub = 4
+		default:
+			return time.Time{}, 0, ErrTimeCode
+		}
+	}
+	if days == 0 {
+		days = 2
+	}
+	if (days != 2 && days != 3) || (sub != 0 && sub != 2 && sub != 4) {
+		return time.Time{}, 0, ErrTimeCode
+	}
+	if epoch.IsZero() {
+		epoch = TAI
+	}
+	if len(bs) < offset+days+4+sub {
+		return time.Time{}, 0, ErrShort
+	}
+	day := readUint(bs[offset : offset+days])
+	offset += days
+	millis := readUint(bs[offset : offset+4])
+	offset += 4
+
+	w := epoch.AddDate(0, 0, int(day)).Add(time.Duration(millis) * time.Millisecond)
+	switch sub {
+	case 2:
+		w = w.Add(time.Duration(readUint(bs[offset:offset+sub])) * time.Microsecond)
+	case 4:
+		w = w.Add(time.Duration(readUint(bs[offset:offset+sub]) / 1000))
+	}
+	offset += sub
+	return w, offset, nil
+}
+
+func ParseEpoch(str string) (time.Time, error) {
+	switch str {
+	case "", "tai", "1958":
+		return TAI, nil
+	case "gps", "1980":
+		return GPS, nil
+	case "unix", "1970":
+		return time.Unix(0, 0).UTC(), nil
+	default:
+		w, err := time.Parse(time.RFC3339, str)
+		if err != nil {
+			return w, fmt.Errorf("ccsds: invalid epoch %s", str)
+		}
+		return w.UTC(), nil
+	}
+}
+
+func readUint(bs []byte) uint64 {
+	var v uint64
+	for _, b := range bs {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
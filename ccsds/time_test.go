@@ -0,0 +1,143 @@
+package ccsds
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCUC(t *testing.T) {
+	data := []struct {
+		Code  CUC
+		Input []byte
+		Want  time.Time
+		Len   int
+		Err   error
+	}{
+		{
+			Code:  CUC{Epoch: GPS, Coarse: 4, Fine: 2},
+			Input: []byte{0x00, 0x00, 0x00, 0x0a, 0x80, 0x00},
+			Want:  GPS.Add(10500 * time.Millisecond),
+			Len:   6,
+		},
+		{
+			Code:  CUC{Coarse: 1},
+			Input: []byte{0x3c, 0xff},
+			Want:  TAI.Add(time.Minute),
+			Len:   1,
+		},
+		{
+			Code:  CUC{PField: true},
+			Input: []byte{0x1e, 0x00, 0x00, 0x00, 0x3c, 0x40, 0x00},
+			Want:  TAI.Add(60250 * time.Millisecond),
+			Len:   7,
+		},
+		{
+			Code:  CUC{Epoch: GPS, PField: true},
+			Input: []byte{0xaf, 0x20, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00},
+			Want:  GPS.Add(time.Second),
+			Len:   10,
+		},
+		{
+			Code:  CUC{Coarse: 4, Fine: 2},
+			Input: []byte{0x00, 0x00, 0x00},
+			Err:   ErrShort,
+		},
+		{
+			Code:  CUC{PField: true},
+			Input: []byte{0x30, 0x00, 0x00, 0x00, 0x00},
+			Err:   ErrTimeCode,
+		},
+		{
+			Code:  CUC{Coarse: 8},
+			Input: make([]byte, 8),
+			Err:   ErrTimeCode,
+		},
+	}
+	for i, d := range data {
+		w, n, err := d.Code.DecodeTime(d.Input)
+		if err != d.Err {
+			t.Errorf("%d: expected error %v, got %v", i, d.Err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !w.Equal(d.Want) || n != d.Len {
+			t.Errorf("%d: expected %s (%d bytes), got %s (%d bytes)", i, d.Want, d.Len, w, n)
+		}
+	}
+}
+
+func TestCDS(t *testing.T) {
+	data := []struct {
+		Code  CDS
+		Input []byte
+		Want  time.Time
+		Len   int
+		Err   error
+	}{
+		{
+			Code:  CDS{},
+			Input: []byte{0x00, 0x01, 0x00, 0x00, 0x03, 0xe8},
+			Want:  TAI.AddDate(0, 0, 1).Add(time.Second),
+			Len:   6,
+		},
+		{
+			Code:  CDS{Epoch: GPS, Days: 3, Sub: 2},
+			Input: []byte{0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x05},
+			Want:  GPS.AddDate(0, 0, 2).Add(10*time.Millisecond + 5*time.Microsecond),
+			Len:   9,
+		},
+		{
+			Code:  CDS{Sub: 4},
+			Input: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07, 0xd0},
+			Want:  TAI.Add(2 * time.Nanosecond),
+			Len:   10,
+		},
+		{
+			Code:  CDS{Epoch: GPS, PField: true},
+			Input: []byte{0x45, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x0a, 0x00, 0x05},
+			Want:  TAI.AddDate(0, 0, 2).Add(10*time.Millisecond + 5*time.Microsecond),
+			Len:   10,
+		},
+		{
+			Code:  CDS{Epoch: GPS, PField: true},
+			Input: []byte{0x48, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00},
+			Want:  GPS.AddDate(0, 0, 1),
+			Len:   7,
+		},
+		{
+			Code:  CDS{},
+			Input: []byte{0x00, 0x01, 0x00, 0x00},
+			Err:   ErrShort,
+		},
+		{
+			Code:  CDS{PField: true},
+			Input: []byte{0x10, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			Err:   ErrTimeCode,
+		},
+		{
+			Code:  CDS{PField: true},
+			Input: []byte{0x43, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+			Err:   ErrTimeCode,
+		},
+		{
+			Code:  CDS{Days: 4},
+			Input: make([]byte, 8),
+			Err:   ErrTimeCode,
+		},
+	}
+	for i, d := range data {
+		w, n, err := d.Code.DecodeTime(d.Input)
+		if err != d.Err {
+			t.Errorf("%d: expected error %v, got %v", i, d.Err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if !w.Equal(d.Want) || n != d.Len {
+			t.Errorf("%d: expected %s (%d bytes), got %s (%d bytes)", i, d.Want, d.Len, w, n)
+		}
+	}
+}
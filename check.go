@@ -2,8 +2,12 @@ package rt
 
 import (
 	// "crypto/md5"
+	"archive/tar"
+	"archive/zip"
+	"fmt"
 	"io"
-	"os"
+	"io/fs"
+	"io/ioutil"
 	"path/filepath"
 	"strings"
 	"time"
@@ -61,33 +65,92 @@ func Dump(csv, strip, invalid, pretty bool) *Dumper {
 }
 
 func (d *Dumper) Dump(w io.Writer, file string) error {
+	return d.DumpFS(w, nil, file)
+}
+
+// DumpFS is like Dump but checks the files of fsys.
+func (d *Dumper) DumpFS(w io.Writer, fsys fs.FS, file string) error {
 	buf := make([]byte, 8<<20)
-	return filepath.Walk(file, func(p string, i os.FileInfo, err error) error {
+	return walkDir(fsys, file, func(p string, e fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
-		if i.IsDir() {
+		if e.IsDir() {
 			return nil
 		}
-		switch filepath.Ext(p) {
-		case ".dat", ".bin":
-		default:
+		if !hasExt(p, ".dat", ".bin") {
 			return nil
 		}
-		s, err := checkFile(buf, p, i)
+		i, err := e.Info()
+		if err != nil {
+			return err
+		}
+		s, err := checkFile(buf, fsys, p, i, nil)
 		if err == nil {
-			if d.strip {
-				s.File = strings.TrimPrefix(p, file)
-				if s.File == "" {
-					s.File = filepath.Base(p)
-				}
-			}
-			d.dumpState(w, s)
+			d.dump(w, s, file)
 		}
 		return err
 	})
 }
 
+// DumpTar is like Dump but checks the files of the tar archive read from r.
+func (d *Dumper) DumpTar(w io.Writer, r io.Reader) error {
+	var (
+		buf = make([]byte, 8<<20)
+		tr  = tar.NewReader(r)
+	)
+	for {
+		h, err := nextTar(tr)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		f, err := newFile(ioutil.NopCloser(tr), h.Name)
+		if err != nil {
+			return err
+		}
+		s := checkReader(buf, f, h.Name, h.ModTime, nil)
+		f.Close()
+		d.dump(w, s, "")
+	}
+}
+
+// DumpBundle is like Dump but checks the files of a zip or tar archive (see
+// IsBundle).
+func (d *Dumper) DumpBundle(w io.Writer, file string) error {
+	switch filepath.Ext(TrimCodec(file)) {
+	case ".zip":
+		z, err := zip.OpenReader(file)
+		if err != nil {
+			return err
+		}
+		defer z.Close()
+		return d.DumpFS(w, z, ".")
+	case ".tar":
+		f, err := Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return d.DumpTar(w, f)
+	default:
+		return fmt.Errorf("bundle: %s: not a zip or tar archive", file)
+	}
+}
+
+func (d *Dumper) dump(w io.Writer, s state, root string) {
+	if d.strip {
+		file := s.File
+		s.File = strings.TrimPrefix(s.File, root)
+		if s.File == "" {
+			s.File = filepath.Base(file)
+		}
+	}
+	d.dumpState(w, s)
+}
+
 func (d *Dumper) dumpState(w io.Writer, s state) {
 	missing := s.Bytes - s.Size
 	d.Size += float64(s.Size)
@@ -126,21 +189,27 @@ func (d *Dumper) dumpState(w io.Writer, s state) {
 	io.Copy(w, d.line)
 }
 
-func checkFile(buf []byte, p string, i os.FileInfo) (state, error) {
-	var s state
-
-	r, err := os.Open(p)
+// checkFile reads the packets of a file and computes its state. When fn is not
+// nil, it is called with the payload of each packet.
+func checkFile(buf []byte, fsys fs.FS, p string, i fs.FileInfo, fn func([]byte)) (state, error) {
+	r, err := OpenFS(fsys, p)
 	if err != nil {
-		return s, err
+		return state{}, err
 	}
 	defer r.Close()
+	return checkReader(buf, r, p, i.ModTime(), fn), nil
+}
 
-	s.LastMod = i.ModTime()
-	s.Bytes = i.Size()
-	s.File = p
-
-	digest := xxh.New64(0)
-	rs := NewReader(io.TeeReader(r, digest))
+func checkReader(buf []byte, r io.Reader, p string, mod time.Time, fn func([]byte)) state {
+	s := state{
+		File:    p,
+		LastMod: mod,
+	}
+	var (
+		digest = xxh.New64(0)
+		count  counter
+		rs     = NewReader(io.TeeReader(r, io.MultiWriter(digest, &count)), WithResync(len(buf)))
+	)
 	for {
 		n, err := rs.Read(buf)
 		if n > 0 {
@@ -153,7 +222,22 @@ func checkFile(buf []byte, p string, i os.FileInfo) (state, error) {
 			break
 		}
 		s.Packets++
+		if fn != nil {
+			fn(buf[4:n])
+		}
+	}
+	if n := rs.Skipped(); n > 0 && s.Err == nil {
+		s.Err = SkipError(n)
 	}
 	s.Sum = digest.Sum64()
-	return s, nil
+	s.Bytes = int64(count)
+	return s
+}
+
+// counter counts the bytes read from files, after decompression.
+type counter int64
+
+func (c *counter) Write(bs []byte) (int, error) {
+	*c += counter(len(bs))
+	return len(bs), nil
 }
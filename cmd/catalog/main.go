@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/busoc/rt"
+	_ "github.com/busoc/rt/ccsds"
+)
+
+func main() {
+	var (
+		db      = flag.String("db", "rt.catalog", "catalog")
+		decoder = flag.String("decoder", "", "decoder")
+		from    = flag.String("from", "", "start time")
+		to      = flag.String("to", "", "end time")
+		pids    rt.PidSet
+	)
+	flag.Var(&pids, "pid", "pid")
+	flag.Parse()
+
+	var dec rt.HeaderDecoder
+	if *decoder != "" {
+		d, err := rt.LookupDecoder(*decoder)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		dec = d
+	}
+	starts, err := parseTime(*from)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	ends, err := parseTime(*to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	c, err := rt.OpenCatalog(*db, dec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if flag.NArg() > 0 {
+		n, err := c.Refresh(flag.Args()...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "%d file(s) updated\n", n)
+	}
+	for _, m := range c.Query(starts, ends, pids...) {
+		fmt.Printf("%s | %s | %7d | %9d | %016x | %v | %s\n", m.StartTime.Format(rt.TimeFormat), m.EndTime.Format(rt.TimeFormat), m.Count, m.Size, m.Sum, m.Pids, m.File)
+	}
+}
+
+func parseTime(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, str)
+}
@@ -17,8 +17,16 @@ func main() {
 	)
 	flag.Parse()
 
-	d := rt.Dump(*csv, *strip, *invalid, *pretty)
-	if err := d.Dump(os.Stdout, flag.Arg(0)); err != nil {
+	var (
+		d   = rt.Dump(*csv, *strip, *invalid, *pretty)
+		err error
+	)
+	if rt.IsBundle(flag.Arg(0)) {
+		err = d.DumpBundle(os.Stdout, flag.Arg(0))
+	} else {
+		err = d.Dump(os.Stdout, flag.Arg(0))
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
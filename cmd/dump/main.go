@@ -13,6 +13,7 @@ import (
 )
 
 func main() {
+	resync := flag.Bool("r", false, "resync")
 	flag.Parse()
 
 	r, err := os.Open(flag.Arg(0))
@@ -32,16 +33,25 @@ func main() {
 	for i := 0; i < len(dirs); i++ {
 		dirs[i] = flag.Arg(i + 1)
 	}
-	br, err := rt.Browse(dirs, true)
+	var br io.ReadCloser
+	if len(dirs) == 1 && rt.IsBundle(dirs[0]) {
+		br, err = rt.BrowseBundle(dirs[0])
+	} else {
+		br, err = rt.Browse(dirs, true)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "browsing", err)
 		os.Exit(3)
 	}
 	defer r.Close()
 
+	var options []rt.ReaderOption
+	if *resync {
+		options = append(options, rt.WithResync(0))
+	}
 	var (
 		buf    = make([]byte, 8<<20)
-		rs     = rt.NewReader(br)
+		rs     = rt.NewReader(br, options...)
 		count  int
 		size   int
 		values int
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/busoc/rt"
+	_ "github.com/busoc/rt/ccsds"
+)
+
+// key identifies a packet in both archives.
+type key struct {
+	Pid      int
+	Sequence uint
+	When     int64
+}
+
+type packet struct {
+	rt.PacketInfo
+	Size int
+}
+
+type pidReport struct {
+	Id        int    `json:"id"`
+	Primary   uint64 `json:"primary"`
+	Recovered uint64 `json:"recovered"`
+	Missing   uint64 `json:"missing"`
+}
+
+type report struct {
+	Pids []pidReport `json:"pids"`
+	Gaps []rt.Gap    `json:"gaps"`
+}
+
+func main() {
+	var (
+		resync  = flag.Bool("r", false, "resync")
+		decoder = flag.String("decoder", "hrdl", "decoder")
+		output  = flag.String("o", "", "archive receiving the recovered packets (required unless -n is given)")
+		dry     = flag.Bool("n", false, "only report the packets that would be recovered")
+		format  = flag.String("format", "table", "output format (table, json)")
+		bits    = flag.Int("bits", 0, "width of sequence counters (0: given by the decoder, 32 bits for hrdl, 14 for ccsds)")
+		scale   = flag.String("scale", "", "time scale of the times given by the decoder (utc, tai, gps), required unless the decoder is hrdl")
+	)
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: fill [options] <primary> <secondary>")
+		os.Exit(1)
+	}
+	dec, err := rt.LookupDecoder(*decoder)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *scale == "" {
+		if name := strings.SplitN(*decoder, ":", 2)[0]; name != "hrdl" {
+			fmt.Fprintf(os.Stderr, "fill: -scale is required with the %s decoder\n", name)
+			os.Exit(1)
+		}
+		*scale = "gps"
+	}
+	layout := *rt.DefaultLayout
+	if layout.Scale, err = rt.ParseScale(*scale); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	switch {
+	case *dry:
+	case *output == "":
+		fmt.Fprintln(os.Stderr, "fill: -o is required to write the recovered packets")
+		os.Exit(1)
+	case rt.IsBundle(*output):
+		fmt.Fprintln(os.Stderr, "fill: can not write the recovered packets in a bundle")
+		os.Exit(1)
+	}
+	var options []rt.ReaderOption
+	if *resync {
+		options = append(options, rt.WithResync(0))
+	}
+
+	var (
+		seen    = make(map[key]struct{})
+		primary []packet
+		untimed int
+	)
+	// without time, pid and sequence are not enough to tell packets apart
+	// once their counter wrapped
+	decode := func(bs []byte) (rt.PacketInfo, bool) {
+		i, err := dec.Decode(bs)
+		if err != nil {
+			return i, false
+		}
+		if i.When.IsZero() {
+			untimed++
+			return i, false
+		}
+		return i, true
+	}
+	err = scan(flag.Arg(0), options, func(bs []byte) error {
+		i, ok := decode(bs)
+		if !ok {
+			return nil
+		}
+		seen[keyOf(i)] = struct{}{}
+		primary = append(primary, packet{PacketInfo: i, Size: len(bs)})
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "primary:", err)
+		os.Exit(2)
+	}
+
+	var (
+		recovered []packet
+		w         *rt.ArchiveWriter
+	)
+	if !*dry {
+		w, err = rt.NewArchiveWriter(*output, &layout, 8)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	err = scan(flag.Arg(1), options, func(bs []byte) error {
+		i, ok := decode(bs)
+		if !ok {
+			return nil
+		}
+		k := keyOf(i)
+		if _, ok := seen[k]; ok {
+			return nil
+		}
+		seen[k] = struct{}{}
+		recovered = append(recovered, packet{PacketInfo: i, Size: len(bs)})
+		if w == nil {
+			return nil
+		}
+		return w.WritePacket(i, bs)
+	})
+	if w != nil {
+		if e := w.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "secondary:", err)
+		os.Exit(2)
+	}
+
+	if untimed > 0 {
+		fmt.Fprintf(os.Stderr, "fill: %d packet(s) without time skipped\n", untimed)
+	}
+	r := makeReport(dec, primary, recovered, *bits)
+	switch *format {
+	case "table":
+		printTable(r)
+	case "json":
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		err = e.Encode(r)
+	default:
+		err = fmt.Errorf("unsupported format %s", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func scan(base string, options []rt.ReaderOption, fn func([]byte) error) error {
+	skip := rt.SkipErrors(func(file string, err error) {
+		fmt.Fprintln(os.Stderr, file, err)
+	})
+	var (
+		mr  io.ReadCloser
+		err error
+	)
+	if rt.IsBundle(base) {
+		mr, err = rt.BrowseBundle(base, skip)
+	} else {
+		mr, err = rt.Browse([]string{base}, true, skip)
+	}
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer mr.Close()
+
+	sc := rt.NewScanner(mr, options...)
+	for sc.Scan() {
+		if err := fn(sc.Packet().Payload); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// makeReport counts the packets of each pid found in the primary archive and
+// recovered from the secondary one, and the gaps left once both are merged.
+// Both lists are sorted by time in place and merged into the Analyzer.
+func makeReport(dec rt.HeaderDecoder, primary, recovered []packet, bits int) report {
+	var (
+		a  = rt.NewAnalyzer(dec)
+		ps = make(map[int]*pidReport)
+	)
+	a.SetCounter(bits)
+	for _, ls := range [][]packet{primary, recovered} {
+		sort.SliceStable(ls, func(i, j int) bool {
+			return ls[i].When.Before(ls[j].When)
+		})
+	}
+	get := func(pid int) *pidReport {
+		r, ok := ps[pid]
+		if !ok {
+			r = &pidReport{Id: pid}
+			ps[pid] = r
+		}
+		return r
+	}
+	for len(primary) > 0 || len(recovered) > 0 {
+		var p packet
+		if len(recovered) == 0 || (len(primary) > 0 && !recovered[0].When.Before(primary[0].When)) {
+			p, primary = primary[0], primary[1:]
+			get(p.Pid).Primary++
+		} else {
+			p, recovered = recovered[0], recovered[1:]
+			get(p.Pid).Recovered++
+		}
+		a.Add(p.PacketInfo, p.Size)
+	}
+	for _, c := range a.Stats() {
+		get(c.Id).Missing = c.Missing
+	}
+	var r report
+	for _, p := range ps {
+		r.Pids = append(r.Pids, *p)
+	}
+	sort.Slice(r.Pids, func(i, j int) bool {
+		return r.Pids[i].Id < r.Pids[j].Id
+	})
+	r.Gaps = a.Gaps()
+	return r
+}
+
+func printTable(r report) {
+	for _, p := range r.Pids {
+		fmt.Printf("%4d | %9d | %9d | %9d\n", p.Id, p.Primary, p.Recovered, p.Missing)
+	}
+	if len(r.Gaps) > 0 {
+		fmt.Println()
+	}
+	for _, g := range r.Gaps {
+		fmt.Printf("%4d | %s | %s | %12s | %8d | %8d | %8d\n", g.Id, g.Starts.Format(rt.TimeFormat), g.Ends.Format(rt.TimeFormat), g.Duration(), g.Last, g.First, g.Missing())
+	}
+}
+
+func keyOf(i rt.PacketInfo) key {
+	return key{
+		Pid:      i.Pid,
+		Sequence: i.Sequence,
+		When:     i.When.UnixNano(),
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/busoc/rt"
+	_ "github.com/busoc/rt/ccsds"
+)
+
+type gap struct {
+	rt.Gap
+	Kind     string  `json:"kind"`
+	Missing  int     `json:"missing"`
+	Duration float64 `json:"duration"`
+}
+
+func main() {
+	var (
+		resync  = flag.Bool("r", false, "resync")
+		decoder = flag.String("decoder", "hrdl", "decoder")
+		format  = flag.String("format", "table", "output format (table, csv, json)")
+		bits    = flag.Int("bits", 0, "width of sequence counters (0: given by the decoder, 32 bits for hrdl, 14 for ccsds)")
+		widths  counters
+		periods periods
+	)
+	flag.Var(&widths, "counter", "width of the sequence counter of a pid (pid:bits)")
+	flag.Var(&periods, "period", "expected period of a pid without counter (pid[:period])")
+	flag.Parse()
+
+	dec, err := rt.LookupDecoder(*decoder)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	skip := rt.SkipErrors(func(file string, err error) {
+		fmt.Fprintln(os.Stderr, file, err)
+	})
+	var mr io.ReadCloser
+	if flag.NArg() == 1 && rt.IsBundle(flag.Arg(0)) {
+		mr, err = rt.BrowseBundle(flag.Arg(0), skip)
+	} else {
+		mr, err = rt.Browse(flag.Args(), true, skip)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer mr.Close()
+
+	var options []rt.ReaderOption
+	if *resync {
+		options = append(options, rt.WithResync(0))
+	}
+	var (
+		a  = rt.NewAnalyzer(dec)
+		sc = rt.NewScanner(mr, options...)
+	)
+	a.SetCounter(*bits)
+	for pid, b := range widths {
+		a.SetPidCounter(pid, b)
+	}
+	for pid, p := range periods {
+		a.SetPeriod(pid, p)
+	}
+	for sc.Scan() {
+		a.Push(sc.Packet().Payload)
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	gs := make([]gap, len(a.Gaps()))
+	for i, g := range a.Gaps() {
+		gs[i] = gap{Gap: g, Kind: "seq", Missing: g.Missing(), Duration: g.Duration().Seconds()}
+		if g.Estimated > 0 {
+			gs[i].Kind = "time"
+		}
+	}
+	switch *format {
+	case "table":
+		printTable(gs, a.Stats(), a.Total())
+	case "csv":
+		err = printCSV(gs, a.Stats(), a.Total())
+	case "json":
+		err = printJSON(gs, a.Stats(), a.Total())
+	default:
+		err = fmt.Errorf("unsupported format %s", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func printTable(gs []gap, cs []rt.Coze, total rt.Coze) {
+	for _, g := range gs {
+		fmt.Printf("%4d | %-4s | %s | %s | %12s | %8d | %8d | %8d\n", g.Id, g.Kind, g.Starts.Format(rt.TimeFormat), g.Ends.Format(rt.TimeFormat), g.Gap.Duration(), g.Last, g.First, g.Missing)
+	}
+	if len(gs) > 0 {
+		fmt.Println()
+	}
+	for _, c := range cs {
+		printCoze(strconv.Itoa(c.Id), c)
+	}
+	printCoze("all", total)
+}
+
+func printCoze(id string, c rt.Coze) {
+	fmt.Printf("%4s | %s | %s | %8d | %10d | %8d | %6d | %6d | %6d\n", id, c.StartTime.Format(rt.TimeFormat), c.EndTime.Format(rt.TimeFormat), c.Count, c.Size, c.Missing, c.Duplicate, c.Reorder, c.Restart)
+}
+
+type counters map[int]int
+
+func (c *counters) String() string {
+	return fmt.Sprint(*c)
+}
+
+func (c *counters) Set(str string) error {
+	ix := strings.IndexByte(str, ':')
+	if ix < 0 {
+		return fmt.Errorf("%s: expected pid:bits", str)
+	}
+	pid, err := strconv.Atoi(str[:ix])
+	if err != nil {
+		return err
+	}
+	bits, err := strconv.Atoi(str[ix+1:])
+	if err != nil {
+		return err
+	}
+	if *c == nil {
+		*c = make(counters)
+	}
+	(*c)[pid] = bits
+	return nil
+}
+
+// printCSV gives the gaps and the counters of each pid in the same table. The
+// first column tells whether a row is a gap, the counters of a pid (stats)
+// or the counters of all the pids (total).
+func printCSV(gs []gap, cs []rt.Coze, total rt.Coze) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"record", "pid", "kind", "dtstart", "dtend", "duration", "last", "first", "missing", "count", "bytes", "error", "duplicate", "reorder", "restart"})
+	for _, g := range gs {
+		w.Write([]string{
+			"gap",
+			strconv.Itoa(g.Id),
+			g.Kind,
+			g.Starts.Format(time.RFC3339Nano),
+			g.Ends.Format(time.RFC3339Nano),
+			strconv.FormatFloat(g.Duration, 'f', -1, 64),
+			strconv.Itoa(g.Last),
+			strconv.Itoa(g.First),
+			strconv.Itoa(g.Missing),
+			"", "", "", "", "", "",
+		})
+	}
+	for _, c := range cs {
+		w.Write(cozeRecord("stats", strconv.Itoa(c.Id), c))
+	}
+	w.Write(cozeRecord("total", "", total))
+	w.Flush()
+	return w.Error()
+}
+
+func cozeRecord(kind, id string, c rt.Coze) []string {
+	return []string{
+		kind,
+		id,
+		"",
+		c.StartTime.Format(time.RFC3339Nano),
+		c.EndTime.Format(time.RFC3339Nano),
+		strconv.FormatFloat(c.EndTime.Sub(c.StartTime).Seconds(), 'f', -1, 64),
+		strconv.FormatUint(c.Last, 10),
+		strconv.FormatUint(c.First, 10),
+		strconv.FormatUint(c.Missing, 10),
+		strconv.FormatUint(c.Count, 10),
+		strconv.FormatUint(c.Size, 10),
+		strconv.FormatUint(c.Error, 10),
+		strconv.FormatUint(c.Duplicate, 10),
+		strconv.FormatUint(c.Reorder, 10),
+		strconv.FormatUint(c.Restart, 10),
+	}
+}
+
+func printJSON(gs []gap, cs []rt.Coze, total rt.Coze) error {
+	report := struct {
+		Gaps  []gap     `json:"gaps"`
+		Stats []rt.Coze `json:"stats"`
+		Total rt.Coze   `json:"total"`
+	}{
+		Gaps:  gs,
+		Stats: cs,
+		Total: total,
+	}
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	return e.Encode(report)
+}
+
+type periods map[int]time.Duration
+
+func (p *periods) String() string {
+	return fmt.Sprint(*p)
+}
+
+func (p *periods) Set(str string) error {
+	var period time.Duration
+	if ix := strings.IndexByte(str, ':'); ix >= 0 {
+		d, err := time.ParseDuration(str[ix+1:])
+		if err != nil {
+			return err
+		}
+		str, period = str[:ix], d
+	}
+	pid, err := strconv.Atoi(str)
+	if err != nil {
+		return err
+	}
+	if *p == nil {
+		*p = make(periods)
+	}
+	(*p)[pid] = period
+	return nil
+}
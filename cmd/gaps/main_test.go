@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs the command instead of the tests when the test binary is
+// started by run.
+func TestMain(m *testing.M) {
+	if os.Getenv("RT_GAPS_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func run(args ...string) (string, string, error) {
+	var (
+		stdout bytes.Buffer
+		stderr bytes.Buffer
+		cmd    = exec.Command(os.Args[0], args...)
+	)
+	cmd.Env = append(os.Environ(), "RT_GAPS_MAIN=1")
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	return stdout.String(), stderr.String(), err
+}
+
+func TestCorruptedFile(t *testing.T) {
+	frame := func(seq uint32) []byte {
+		bs := make([]byte, 28)
+		binary.LittleEndian.PutUint32(bs, 24)
+		bs[12] = 1
+		binary.LittleEndian.PutUint32(bs[16:], seq)
+		binary.LittleEndian.PutUint32(bs[20:], 1272000000+seq)
+		return bs
+	}
+	var (
+		dir   = t.TempDir()
+		files = map[string][]byte{
+			"rt_00_00.dat": bytes.Join([][]byte{frame(1), frame(2), frame(3)}, nil),
+			"rt_00_05.dat": bytes.Join([][]byte{frame(4), frame(6), frame(7)[:10]}, nil),
+			"rt_00_10.dat": bytes.Join([][]byte{frame(8), frame(9)}, nil),
+		}
+	)
+	for n, bs := range files {
+		if err := os.WriteFile(filepath.Join(dir, n), bs, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stdout, stderr, err := run("-format", "csv", dir)
+	if err != nil {
+		t.Fatalf("gaps failed: %v (%s)", err, stderr)
+	}
+	if !strings.Contains(stderr, "rt_00_05.dat") {
+		t.Errorf("corrupted file not reported: %q", stderr)
+	}
+	rows, err := csv.NewReader(strings.NewReader(stdout)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		Record string
+		Count  string
+	}{
+		{Record: "gap"},
+		{Record: "gap"},
+		{Record: "stats", Count: "7"},
+		{Record: "total", Count: "7"},
+	}
+	if len(rows) != len(want)+1 {
+		t.Fatalf("got %d rows, want %d: %q", len(rows), len(want)+1, rows)
+	}
+	for i, w := range want {
+		if r := rows[i+1]; r[0] != w.Record || r[9] != w.Count {
+			t.Errorf("row %d: got %s with count %q, want %s with count %q", i+1, r[0], r[9], w.Record, w.Count)
+		}
+	}
+}
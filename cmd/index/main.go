@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/busoc/rt"
+	_ "github.com/busoc/rt/ccsds"
+)
+
+func main() {
+	var (
+		decoder = flag.String("decoder", "", "decoder")
+		force   = flag.Bool("f", false, "rebuild")
+	)
+	flag.Parse()
+
+	var dec rt.HeaderDecoder
+	if *decoder != "" {
+		d, err := rt.LookupDecoder(*decoder)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		dec = d
+	}
+	var errs int
+	for _, a := range flag.Args() {
+		err := filepath.Walk(a, func(p string, i os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if i.IsDir() || !isData(p) {
+				return nil
+			}
+			if *force {
+				os.Remove(rt.IndexPath(p))
+			}
+			x, ok, err := rt.IndexFile(p, dec)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, p, err)
+				errs++
+				return nil
+			}
+			status := "ok"
+			if ok {
+				status = "updated"
+			}
+			fmt.Printf("%-8s | %7d | %s\n", status, len(x.Entries), p)
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			errs++
+		}
+	}
+	if errs > 0 {
+		os.Exit(2)
+	}
+}
+
+func isData(p string) bool {
+	return filepath.Ext(rt.TrimCodec(p)) == ".dat"
+}
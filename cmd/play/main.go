@@ -4,21 +4,58 @@ import (
   "flag"
   "net"
   "fmt"
+  "io"
   "os"
   "time"
 
   "github.com/busoc/rt"
+  _ "github.com/busoc/rt/ccsds"
 )
 
 func main() {
   sleep := flag.Duration("s", time.Second, "sleep time")
+  resync := flag.Bool("r", false, "resync")
+  decoder := flag.String("decoder", "hrdl", "decoder")
+  from := flag.String("from", "", "start time (indexed files)")
+  var pids rt.PidSet
+  flag.Var(&pids, "pid", "pid")
   flag.Parse()
 
+  var dec rt.HeaderDecoder
+  if *decoder != "" {
+    d, err := rt.LookupDecoder(*decoder)
+    if err != nil {
+      fmt.Fprintln(os.Stderr, "decoder", err)
+      os.Exit(3)
+    }
+    dec = d
+  } else if len(pids) > 0 {
+    fmt.Fprintln(os.Stderr, "pid filter needs a decoder")
+    os.Exit(3)
+  }
+
   dirs := make([]string, flag.NArg()-1)
 	for i := 0; i < len(dirs); i++ {
 		dirs[i] = flag.Arg(i + 1)
 	}
-	br, err := rt.Browse(dirs, true)
+	var browse []rt.BrowseOption
+	if *from != "" {
+		t, err := time.Parse(time.RFC3339, *from)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "from", err)
+			os.Exit(3)
+		}
+		browse = append(browse, rt.StartAt(t))
+	}
+	var (
+		br  io.ReadCloser
+		err error
+	)
+	if len(dirs) == 1 && rt.IsBundle(dirs[0]) {
+		br, err = rt.BrowseBundle(dirs[0], browse...)
+	} else {
+		br, err = rt.Browse(dirs, true, browse...)
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "browsing", err)
 		os.Exit(3)
@@ -32,9 +69,16 @@ func main() {
   }
   defer w.Close()
 
+  var options []rt.ReaderOption
+  if *resync {
+    options = append(options, rt.WithResync(0))
+  }
+  if len(pids) > 0 {
+    options = append(options, rt.WithMatch(rt.MatchPid(dec.Decode, pids...)))
+  }
   var (
     buf = make([]byte, 8<<20)
-    rs  = rt.NewReader(br)
+    rs  = rt.NewReader(br, options...)
   )
   for {
     n, err := rs.Read(buf)
@@ -5,16 +5,88 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/busoc/rt"
+	_ "github.com/busoc/rt/ccsds"
 	"github.com/midbel/xxh"
 )
 
 func main() {
-	list := flag.Bool("l", false, "list")
+	var (
+		list    = flag.Bool("l", false, "list")
+		resync  = flag.Bool("r", false, "resync")
+		decoder = flag.String("decoder", "", "decoder of the headers listed with -l (hrdl if -pid or -catalog is given)")
+		catalog = flag.String("catalog", "", "catalog")
+		from    = flag.String("from", "", "start time of the files selected in the catalog")
+		to      = flag.String("to", "", "end time of the files selected in the catalog")
+		follow  = flag.Bool("f", false, "follow")
+		pids    rt.PidSet
+	)
+	flag.Var(&pids, "pid", "pid")
 	flag.Parse()
 
-	mr, err := rt.Browse(flag.Args(), true)
+	if *decoder == "" && (len(pids) > 0 || *catalog != "") {
+		*decoder = "hrdl"
+	}
+	if *catalog == "" && (*from != "" || *to != "") {
+		fmt.Fprintln(os.Stderr, "-from and -to need a catalog")
+		os.Exit(1)
+	}
+	starts, err := parseTime(*from)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	ends, err := parseTime(*to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var dec rt.HeaderDecoder
+	if *decoder != "" {
+		d, err := rt.LookupDecoder(*decoder)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		dec = d
+	}
+
+	skip := rt.SkipErrors(func(file string, err error) {
+		fmt.Fprintln(os.Stderr, file, err)
+	})
+	var mr io.ReadCloser
+	switch {
+	case *follow:
+		mr, err = rt.Follow(flag.Arg(0), nil, skip)
+	case flag.NArg() == 1 && rt.IsBundle(flag.Arg(0)):
+		mr, err = rt.BrowseBundle(flag.Arg(0), skip)
+	case *catalog != "":
+		c, e := rt.OpenCatalog(*catalog, dec)
+		if e != nil {
+			fmt.Fprintln(os.Stderr, e)
+			os.Exit(1)
+		}
+		if _, e := c.Refresh(flag.Args()...); e != nil {
+			fmt.Fprintln(os.Stderr, e)
+			os.Exit(1)
+		}
+		var files []string
+		for _, f := range c.Select(starts, ends, pids...) {
+			if under(f, flag.Args()) {
+				files = append(files, f)
+			}
+		}
+		mr, err = rt.Browse(files, false, skip)
+	default:
+		mr, err = rt.Browse(flag.Args(), true, skip)
+	}
+	if err == io.EOF {
+		return
+	}
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -22,26 +94,61 @@ func main() {
 	defer mr.Close()
 
 	var (
-		size int
-		bad  int
-		sum  = xxh.New64(0)
-		buf  = make([]byte, 8<<20)
-		rs   = io.TeeReader(rt.NewReader(mr), sum)
+		size    int
+		count   int
+		skipped int
+		options []rt.ReaderOption
 	)
-	for i := 1; ; i++ {
-		n, err := rs.Read(buf)
-		switch err {
-		case nil:
-			if *list {
-				fmt.Printf("%7d | %7d | %016x\n", i, n, sum.Sum64())
-			}
-			size += n - 4
-		case io.EOF, rt.ErrInvalid:
-			fmt.Printf("%d packets (%d invalid, %dKB)\n", i-1, bad, size>>10)
-			return
-		default:
-			i--
-			bad++
+	if *resync {
+		options = append(options, rt.WithResync(0), rt.OnSkip(func(n int) { skipped += n }))
+	}
+	if len(pids) > 0 {
+		options = append(options, rt.WithMatch(rt.MatchPid(dec.Decode, pids...)))
+	}
+	var (
+		sum = xxh.New64(0)
+		sc  = rt.NewScanner(mr, options...)
+	)
+	for sc.Scan() {
+		p := sc.Packet()
+		sum.Write(p.Payload)
+		size += len(p.Payload)
+		count++
+		if !*list {
+			continue
+		}
+		if dec == nil {
+			fmt.Printf("%7d | %7d | %016x | %s:%d\n", count, len(p.Payload), sum.Sum64(), p.File, p.Offset)
+			continue
+		}
+		i, err := dec.Decode(p.Payload)
+		if err != nil {
+			fmt.Printf("%7d | %7d | %016x | %s | %s:%d\n", count, len(p.Payload), sum.Sum64(), err, p.File, p.Offset)
+			continue
+		}
+		fmt.Printf("%7d | %7d | %016x | %4d | %8d | %s | %s:%d\n", count, len(p.Payload), sum.Sum64(), i.Pid, i.Sequence, i.When.Format(rt.TimeFormat), p.File, p.Offset)
+	}
+	fmt.Printf("%d packets (%dKB, %d bytes skipped)\n", count, size>>10, skipped)
+	if err := sc.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func parseTime(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, str)
+}
+
+// under reports whether file is one of the given roots or is in one of them.
+func under(file string, roots []string) bool {
+	for _, r := range roots {
+		r = filepath.Clean(r)
+		if file == r || strings.HasPrefix(file, r+string(filepath.Separator)) || r == "." && !filepath.IsAbs(file) {
+			return true
 		}
 	}
+	return false
 }
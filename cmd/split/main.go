@@ -12,9 +12,12 @@ import (
 func main() {
 	datadir := flag.String("d", os.TempDir(), "data directory")
 	part := flag.Int("n", 0, "part")
+	resync := flag.Bool("r", false, "resync")
+	codec := flag.String("z", "", "compression")
+	level := flag.Int("l", 0, "compression level")
 	flag.Parse()
 
-	f, err := os.Open(flag.Arg(0))
+	f, err := rt.Open(flag.Arg(0))
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(2)
@@ -25,14 +28,18 @@ func main() {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(2)
 	}
-	w, err := rt.Split(*datadir, *part)
+	w, err := rt.SplitCompress(*datadir, *part, *codec, *level)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(2)
 	}
 	defer w.Close()
 
-	_, err = io.CopyBuffer(w, rt.NewReader(f), make([]byte, 8<<20))
+	var options []rt.ReaderOption
+	if *resync {
+		options = append(options, rt.WithResync(0))
+	}
+	_, err = io.CopyBuffer(w, rt.NewReader(f, options...), make([]byte, 8<<20))
 	if err != nil && err != io.EOF {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(2)
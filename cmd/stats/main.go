@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/busoc/rt"
+	_ "github.com/busoc/rt/ccsds"
+)
+
+func main() {
+	var (
+		resync  = flag.Bool("r", false, "resync")
+		decoder = flag.String("decoder", "hrdl", "decoder")
+		format  = flag.String("format", "csv", "output format (csv, json)")
+		bucket  = flag.Duration("bucket", rt.Five, "bucket size (eg: 1m, 5m, 1h, 24h)")
+		bits    = flag.Int("bits", 0, "width of sequence counters (0: given by the decoder, 32 bits for hrdl, 14 for ccsds)")
+	)
+	flag.Parse()
+
+	if *bucket <= 0 {
+		fmt.Fprintln(os.Stderr, "bucket size should be positive")
+		os.Exit(1)
+	}
+	dec, err := rt.LookupDecoder(*decoder)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	skip := rt.SkipErrors(func(file string, err error) {
+		fmt.Fprintln(os.Stderr, file, err)
+	})
+	var mr io.ReadCloser
+	if flag.NArg() == 1 && rt.IsBundle(flag.Arg(0)) {
+		mr, err = rt.BrowseBundle(flag.Arg(0), skip)
+	} else {
+		mr, err = rt.Browse(flag.Args(), true, skip)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer mr.Close()
+
+	var options []rt.ReaderOption
+	if *resync {
+		options = append(options, rt.WithResync(0))
+	}
+	var (
+		a  = rt.NewAnalyzer(dec)
+		sc = rt.NewScanner(mr, options...)
+	)
+	a.SetCounter(*bits)
+	a.SetBucket(*bucket)
+	for sc.Scan() {
+		a.Push(sc.Packet().Payload)
+	}
+	if err := sc.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	switch *format {
+	case "csv":
+		err = printCSV(a.Buckets())
+	case "json":
+		e := json.NewEncoder(os.Stdout)
+		e.SetIndent("", "  ")
+		err = e.Encode(a.Buckets())
+	default:
+		err = fmt.Errorf("unsupported format %s", *format)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func printCSV(bs []rt.Bucket) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"bucket", "pid", "dtstart", "dtend", "first", "last", "count", "bytes", "missing", "error", "duplicate", "reorder", "restart"})
+	for _, b := range bs {
+		w.Write([]string{
+			b.Time.Format(time.RFC3339),
+			strconv.Itoa(b.Id),
+			b.StartTime.Format(time.RFC3339Nano),
+			b.EndTime.Format(time.RFC3339Nano),
+			strconv.FormatUint(b.First, 10),
+			strconv.FormatUint(b.Last, 10),
+			strconv.FormatUint(b.Count, 10),
+			strconv.FormatUint(b.Size, 10),
+			strconv.FormatUint(b.Missing, 10),
+			strconv.FormatUint(b.Error, 10),
+			strconv.FormatUint(b.Duplicate, 10),
+			strconv.FormatUint(b.Reorder, 10),
+			strconv.FormatUint(b.Restart, 10),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,260 @@
+package rt
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+var codecs = struct {
+	sync.RWMutex
+	readers map[string]func(io.Reader) (io.ReadCloser, error)
+	writers map[string]func(io.Writer, int) (io.WriteCloser, error)
+}{
+	readers: make(map[string]func(io.Reader) (io.ReadCloser, error)),
+	writers: make(map[string]func(io.Writer, int) (io.WriteCloser, error)),
+}
+
+func init() {
+	RegisterDecompressor(".gz", func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	})
+	RegisterDecompressor(".bz2", func(r io.Reader) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bzip2.NewReader(r)), nil
+	})
+	RegisterDecompressor(".zst", func(r io.Reader) (io.ReadCloser, error) {
+		z, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return z.IOReadCloser(), nil
+	})
+	RegisterDecompressor(".xz", func(r io.Reader) (io.ReadCloser, error) {
+		z, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(z), nil
+	})
+
+	RegisterCompressor(".gz", func(w io.Writer, level int) (io.WriteCloser, error) {
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	})
+	RegisterCompressor(".zst", func(w io.Writer, level int) (io.WriteCloser, error) {
+		var options []zstd.EOption
+		if level > 0 {
+			options = append(options, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, options...)
+	})
+	RegisterCompressor(".xz", func(w io.Writer, _ int) (io.WriteCloser, error) {
+		return xz.NewWriter(w)
+	})
+}
+
+// RegisterDecompressor registers the func used to read files with the given
+// extension (eg: .gz). Files named like file.dat.gz are then transparently
+// decompressed by Open, Browse and the Dumper.
+func RegisterDecompressor(ext string, fn func(io.Reader) (io.ReadCloser, error)) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.readers[ext] = fn
+}
+
+// RegisterCompressor registers the func used to compress files with the given
+// extension. The level is specific to the codec, 0 giving its default level.
+func RegisterCompressor(ext string, fn func(io.Writer, int) (io.WriteCloser, error)) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.writers[ext] = fn
+}
+
+func compressor(ext string) (func(io.Writer, int) (io.WriteCloser, error), bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	fn, ok := codecs.writers[ext]
+	return fn, ok
+}
+
+func decompressor(file string) (func(io.Reader) (io.ReadCloser, error), bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	fn, ok := codecs.readers[filepath.Ext(file)]
+	return fn, ok
+}
+
+// TrimCodec removes the extension of a registered codec from file.
+func TrimCodec(file string) string {
+	if _, ok := decompressor(file); ok {
+		return strings.TrimSuffix(file, filepath.Ext(file))
+	}
+	return file
+}
+
+// hasExt reports whether file has one of the given extensions once the
+// extension of a registered codec is removed.
+func hasExt(file string, exts ...string) bool {
+	e := filepath.Ext(TrimCodec(file))
+	for _, x := range exts {
+		if e == x {
+			return true
+		}
+	}
+	return false
+}
+
+type File struct {
+	io.Reader
+	name  string
+	inner io.Closer
+	codec io.Closer
+	pos   int64
+
+	// set by BrowseRange on the files partially in the requested interval
+	frames *frameFilter
+}
+
+// Open opens the given file and decompresses it on the fly when its
+// extension is the one of a registered codec.
+func Open(file string) (*File, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	return newFile(r, file)
+}
+
+// OpenFS is like Open but opens the named file of fsys. A nil fsys is the OS
+// filesystem.
+func OpenFS(fsys fs.FS, name string) (*File, error) {
+	if fsys == nil {
+		return Open(name)
+	}
+	r, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return newFile(r, name)
+}
+
+func newFile(r io.ReadCloser, file string) (*File, error) {
+	f := File{
+		Reader: r,
+		name:   file,
+		inner:  r,
+	}
+	if fn, ok := decompressor(file); ok {
+		c, err := fn(r)
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+		f.Reader, f.codec = c, c
+	}
+	return &f, nil
+}
+
+// Create creates the given file, truncating it if it already exists, and
+// compresses on the fly what is written to it when its extension is the one
+// of a registered codec.
+func Create(file string, level int) (io.WriteCloser, error) {
+	return openWriter(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, level)
+}
+
+// openWriter opens file with the given flags. When data are appended to a
+// compressed file, they are written as a new stream, which is supported by
+// all the registered codecs.
+func openWriter(file string, flag, level int) (io.WriteCloser, error) {
+	w, err := os.OpenFile(file, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := compressor(filepath.Ext(file))
+	if !ok {
+		return w, nil
+	}
+	c, err := fn(w, level)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	return &compressWriter{WriteCloser: c, file: w}, nil
+}
+
+type compressWriter struct {
+	io.WriteCloser
+	file *os.File
+}
+
+func (c *compressWriter) Close() error {
+	err := c.WriteCloser.Close()
+	if e := c.file.Close(); e != nil {
+		err = e
+	}
+	return err
+}
+
+func (f *File) Name() string {
+	return f.name
+}
+
+func (f *File) Read(bs []byte) (int, error) {
+	n, err := f.Reader.Read(bs)
+	f.pos += int64(n)
+	return n, err
+}
+
+// Offset gives the position of f in its decompressed content.
+func (f *File) Offset() int64 {
+	return f.offsetOf(f.pos)
+}
+
+// offsetOf gives the position in the decompressed content of f of the byte
+// at pos of what f gave, which differ when frames were dropped by its filter.
+func (f *File) offsetOf(pos int64) int64 {
+	if f.frames == nil {
+		return pos
+	}
+	return f.frames.offsetOf(pos)
+}
+
+// seek moves f to the given position of its decompressed content. Compressed
+// files can only be moved forward.
+func (f *File) seek(offset int64) error {
+	if s, ok := f.inner.(io.Seeker); ok && f.codec == nil {
+		pos, err := s.Seek(offset, io.SeekStart)
+		if err == nil {
+			f.pos = pos
+		}
+		return err
+	}
+	if offset < f.pos {
+		return fmt.Errorf("rt: %s: can not seek backward in compressed file", f.name)
+	}
+	_, err := io.CopyN(ioutil.Discard, f, offset-f.pos)
+	return err
+}
+
+func (f *File) Close() error {
+	var err error
+	if f.codec != nil {
+		err = f.codec.Close()
+	}
+	if e := f.inner.Close(); e != nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package rt
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBrowseGzip(t *testing.T) {
+	dir := t.TempDir()
+	for _, n := range []string{"rt_00_05.dat.gz", "rt_05_10.dat.gz"} {
+		f, err := os.Create(filepath.Join(dir, n))
+		if err != nil {
+			t.Fatal(err)
+		}
+		z := gzip.NewWriter(f)
+		w := NewWriter(z)
+		for _, p := range []string{"alpha", "beta", "gamma"} {
+			if _, err := w.Write([]byte(p)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := z.Close(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	r, err := Browse([]string{dir}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var (
+		rs   = NewReader(r)
+		buf  = make([]byte, 1024)
+		got  []string
+		want = []string{"alpha", "beta", "gamma", "alpha", "beta", "gamma"}
+	)
+	for {
+		n, err := rs.Read(buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("packet %d: %v", len(got), err)
+		}
+		got = append(got, string(buf[4:n]))
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d packets, want %d (%q)", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("packet %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package rt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HeaderDecoder extracts the identification and the time of a packet from its
+// payload (without the rt length prefix).
+type HeaderDecoder interface {
+	Decode([]byte) (PacketInfo, error)
+}
+
+type DecoderFunc func([]byte) (PacketInfo, error)
+
+// CounterDecoder is implemented by the HeaderDecoders that know the width in
+// bits of the sequence counter of the packets they decode (eg: 14 bits for
+// CCSDS space packets).
+type CounterDecoder interface {
+	HeaderDecoder
+	CounterBits() int
+}
+
+func (f DecoderFunc) Decode(bs []byte) (PacketInfo, error) {
+	return f(bs)
+}
+
+// OffsetFunc adapts a HeaderDecoder to the func expected by NewMerger and
+// MergeFiles. This func receives full frames, length prefix included.
+func OffsetFunc(d HeaderDecoder) func([]byte) (Offset, error) {
+	return func(bs []byte) (Offset, error) {
+		if len(bs) < 4 {
+			return Offset{}, ErrInvalid
+		}
+		bs = bs[4:]
+		i, err := d.Decode(bs)
+		if err != nil {
+			return Offset{}, err
+		}
+		o := Offset{
+			Pid:      uint(i.Pid),
+			Time:     i.When,
+			Sequence: i.Sequence,
+			Len:      uint(len(bs)),
+		}
+		return o, nil
+	}
+}
+
+var registry = struct {
+	sync.RWMutex
+	decoders map[string]func(string) (HeaderDecoder, error)
+}{
+	decoders: make(map[string]func(string) (HeaderDecoder, error)),
+}
+
+func init() {
+	RegisterDecoder("hrdl", func(_ string) (HeaderDecoder, error) {
+		return DecoderFunc(decodeVMU), nil
+	})
+	RegisterDecoder("generic", parseGeneric)
+}
+
+// RegisterDecoder makes a HeaderDecoder available under the given name. The
+// func receives the arguments given after the name (see LookupDecoder).
+func RegisterDecoder(name string, fn func(string) (HeaderDecoder, error)) {
+	registry.Lock()
+	defer registry.Unlock()
+	if fn == nil {
+		panic("rt: nil decoder for " + name)
+	}
+	if _, ok := registry.decoders[name]; ok {
+		panic("rt: decoder " + name + " already registered")
+	}
+	registry.decoders[name] = fn
+}
+
+// LookupDecoder returns the HeaderDecoder registered under name. Arguments for
+// the decoder can be given after a colon, eg: generic:pid=0/2,time=4/4.
+func LookupDecoder(spec string) (HeaderDecoder, error) {
+	var args string
+	if ix := strings.IndexByte(spec, ':'); ix >= 0 {
+		spec, args = spec[:ix], spec[ix+1:]
+	}
+	registry.RLock()
+	fn, ok := registry.decoders[spec]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("decoder: %s not registered", spec)
+	}
+	return fn(args)
+}
+
+func Decoders() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	names := make([]string, 0, len(registry.decoders))
+	for n := range registry.decoders {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const (
+	vmuHeaderLen   = 24
+	vmuFlagsOffset = 22
+	vmuUPIOffset   = 48
+	vmuUPILen      = 32
+)
+
+var GPS = time.Date(1980, 1, 6, 0, 0, 0, 0, time.UTC)
+
+// decodeVMU decodes the HRDL and VMU headers that start the payload of the
+// packets recorded from the HRDL links: 8 bytes of HRDL header, then channel,
+// origin, 2 spare bytes, sequence counter, coarse and fine time (GPS) and the
+// segmentation flags. The UPI is extracted from the data header when the
+// payload is long enough.
+//
+// The two highest bits of the segmentation flags give the position of the
+// packet in a product split across several packets, with the values of
+// Segment: 0 for a packet carrying a whole product, 1 for the first segment, 2
+// for a continuation and 3 for the last segment.
+func decodeVMU(bs []byte) (PacketInfo, error) {
+	var i PacketInfo
+	if len(bs) < vmuHeaderLen {
+		return i, ErrInvalid
+	}
+	i.Pid = int(bs[8])
+	i.Sid = int(bs[9])
+	i.Sequence = uint(binary.LittleEndian.Uint32(bs[12:]))
+
+	coarse := binary.LittleEndian.Uint32(bs[16:])
+	fine := binary.LittleEndian.Uint16(bs[20:])
+	i.When = GPS.Add(time.Duration(coarse) * time.Second)
+	i.When = i.When.Add(time.Duration(fine) * time.Second / (1 << 16))
+	i.Segment = Segment(bs[vmuFlagsOffset] >> 6)
+
+	if len(bs) >= vmuUPIOffset+vmuUPILen {
+		upi := bs[vmuUPIOffset : vmuUPIOffset+vmuUPILen]
+		i.UPI = string(bytes.Trim(upi, "\x00 "))
+	}
+	return i, nil
+}
+
+type field struct {
+	offset int
+	width  int
+}
+
+func (f field) valid() bool {
+	return f.width > 0
+}
+
+func (f field) decode(bs []byte) (uint64, error) {
+	if f.offset+f.width > len(bs) {
+		return 0, ErrInvalid
+	}
+	var v uint64
+	for _, b := range bs[f.offset : f.offset+f.width] {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+// generic decodes big endian fields at fixed positions in the payload. A
+// time field of 4 bytes or less holds seconds since the Unix epoch, a wider
+// field holds nanoseconds.
+type generic struct {
+	pid  field
+	sid  field
+	seq  field
+	when field
+}
+
+// parseGeneric parses a comma separated list of name=offset/width with name
+// being one of pid, sid, seq and time.
+func parseGeneric(args string) (HeaderDecoder, error) {
+	var g generic
+	if args == "" {
+		return nil, fmt.Errorf("generic: no field given")
+	}
+	for _, a := range strings.Split(args, ",") {
+		ix := strings.IndexByte(a, '=')
+		if ix < 0 {
+			return nil, fmt.Errorf("generic: invalid syntax %s", a)
+		}
+		f, err := parseField(a[ix+1:])
+		if err != nil {
+			return nil, err
+		}
+		switch name := strings.TrimSpace(a[:ix]); name {
+		case "pid":
+			g.pid = f
+		case "sid":
+			g.sid = f
+		case "seq":
+			g.seq = f
+		case "time":
+			g.when = f
+		default:
+			return nil, fmt.Errorf("generic: unknown field %s", name)
+		}
+	}
+	return g, nil
+}
+
+func parseField(str string) (field, error) {
+	var (
+		f   field
+		err error
+	)
+	ix := strings.IndexByte(str, '/')
+	if ix < 0 {
+		return f, fmt.Errorf("generic: invalid field %s (offset/width expected)", str)
+	}
+	if f.offset, err = strconv.Atoi(str[:ix]); err != nil {
+		return f, err
+	}
+	if f.width, err = strconv.Atoi(str[ix+1:]); err != nil {
+		return f, err
+	}
+	if f.offset < 0 || f.width <= 0 || f.width > 8 {
+		return f, fmt.Errorf("generic: invalid field %s", str)
+	}
+	return f, nil
+}
+
+func (g generic) Decode(bs []byte) (PacketInfo, error) {
+	var i PacketInfo
+	if g.pid.valid() {
+		v, err := g.pid.decode(bs)
+		if err != nil {
+			return i, err
+		}
+		i.Pid = int(v)
+	}
+	if g.sid.valid() {
+		v, err := g.sid.decode(bs)
+		if err != nil {
+			return i, err
+		}
+		i.Sid = int(v)
+	}
+	if g.seq.valid() {
+		v, err := g.seq.decode(bs)
+		if err != nil {
+			return i, err
+		}
+		i.Sequence = uint(v)
+	}
+	if g.when.valid() {
+		v, err := g.when.decode(bs)
+		if err != nil {
+			return i, err
+		}
+		if g.when.width <= 4 {
+			i.When = time.Unix(int64(v), 0).UTC()
+		} else {
+			i.When = time.Unix(0, int64(v)).UTC()
+		}
+	}
+	return i, nil
+}
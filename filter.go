@@ -0,0 +1,121 @@
+package rt
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func MatchAll(fs ...MatchFunc) MatchFunc {
+	return func(bs []byte) bool {
+		for _, f := range fs {
+			if !f(bs) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func MatchAny(fs ...MatchFunc) MatchFunc {
+	return func(bs []byte) bool {
+		for _, f := range fs {
+			if f(bs) {
+				return true
+			}
+		}
+		return len(fs) == 0
+	}
+}
+
+func MatchNot(f MatchFunc) MatchFunc {
+	return func(bs []byte) bool {
+		return !f(bs)
+	}
+}
+
+// MatchSize accepts payloads of at least min bytes and at most max bytes. A
+// max of 0 means no upper limit. The Reader should be configured with a window
+// large enough to give the full payload.
+func MatchSize(min, max int) MatchFunc {
+	return func(bs []byte) bool {
+		if len(bs) < min {
+			return false
+		}
+		return max <= 0 || len(bs) <= max
+	}
+}
+
+func MatchBytes(offset int, pattern []byte) MatchFunc {
+	return func(bs []byte) bool {
+		if offset < 0 || offset+len(pattern) > len(bs) {
+			return false
+		}
+		return bytes.Equal(bs[offset:offset+len(pattern)], pattern)
+	}
+}
+
+func MatchPid(get func([]byte) (PacketInfo, error), pids ...int) MatchFunc {
+	set := make(map[int]struct{})
+	for _, p := range pids {
+		set[p] = struct{}{}
+	}
+	return func(bs []byte) bool {
+		i, err := get(bs)
+		if err != nil {
+			return false
+		}
+		_, ok := set[i.Pid]
+		return ok
+	}
+}
+
+func MatchSid(get func([]byte) (PacketInfo, error), sids ...int) MatchFunc {
+	set := make(map[int]struct{})
+	for _, s := range sids {
+		set[s] = struct{}{}
+	}
+	return func(bs []byte) bool {
+		i, err := get(bs)
+		if err != nil {
+			return false
+		}
+		_, ok := set[i.Sid]
+		return ok
+	}
+}
+
+// MatchInterval accepts packets whose time is in [starts, ends). A zero time
+// leaves the corresponding side of the interval open.
+func MatchInterval(get func([]byte) (PacketInfo, error), starts, ends time.Time) MatchFunc {
+	return func(bs []byte) bool {
+		i, err := get(bs)
+		if err != nil {
+			return false
+		}
+		if !starts.IsZero() && i.When.Before(starts) {
+			return false
+		}
+		return ends.IsZero() || i.When.Before(ends)
+	}
+}
+
+// PidSet is a list of pids that can be given as a flag.Value, eg: -pid 1,2 -pid 3.
+type PidSet []int
+
+func (p *PidSet) String() string {
+	return fmt.Sprint(*p)
+}
+
+func (p *PidSet) Set(str string) error {
+	for _, s := range strings.Split(str, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		*p = append(*p, n)
+	}
+	return nil
+}
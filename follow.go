@@ -0,0 +1,312 @@
+package rt
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	defaultPolling = time.Second
+	defaultDrain   = 10 * time.Second
+)
+
+// WithDrain sets how long Follow keeps reading a file once the next file of
+// the archive exists. Follow moves to the next file when the current one did
+// not grow for this duration, so that the packets appended late to it (eg: by
+// an ArchiveWriter keeping several files open) are still given. The default
+// is 10 seconds.
+func WithDrain(idle time.Duration) BrowseOption {
+	return func(c *browseConfig) {
+		if idle >= 0 {
+			c.drain = idle
+		}
+	}
+}
+
+// WithPolling sets how often Follow checks for new data. It also disables the
+// notifications of the OS (eg: inotify) that Follow uses when available.
+func WithPolling(every time.Duration) BrowseOption {
+	return func(c *browseConfig) {
+		c.polling = every
+	}
+}
+
+// Follow gives a never ending stream made of the .dat files of the archive
+// rooted at base (organized according to l) as they are written, like tail -f.
+// Unless StartAt is given, the stream starts at the end of the latest file of
+// the archive. Follow blocks until this file exists.
+//
+// At the end of a file, the stream waits for more data and only gives frames
+// once they are complete. It moves to the next file of the archive when this
+// file exists and the current file did not grow for a while (see WithDrain);
+// a frame left incomplete in the previous file is then dropped and reported to
+// the func given with SkipErrors. Packets appended to a file after the stream
+// moved on are not given. The stream stops when it is closed or when the
+// context given with WithContext is cancelled. Compressed files are ignored.
+func Follow(base string, l *Layout, options ...BrowseOption) (io.ReadCloser, error) {
+	if l == nil {
+		l = DefaultLayout
+	}
+	var (
+		cfg         = newBrowseConfig(append([]BrowseOption{WithLayout(l)}, options...))
+		ctx, cancel = context.WithCancel(cfg.ctx)
+		f           = follower{
+			base: base,
+			cfg:  cfg,
+			ctx:  ctx,
+		}
+	)
+	if cfg.polling > 0 {
+		f.watch = poller(cfg.polling)
+	} else {
+		f.watch = newWatcher(base, defaultPolling)
+	}
+	m, err := newMultiReader(ctx, cancel, f.next, cfg.skip)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	m.bundle = &f
+	return m, nil
+}
+
+type follower struct {
+	base  string
+	cfg   browseConfig
+	ctx   context.Context
+	watch watcher
+
+	current string
+	starts  time.Time
+	ends    time.Time
+	pending string
+	walked  time.Time
+}
+
+// next opens the file following the current one, waiting for it to exist.
+func (f *follower) next() (*File, error) {
+	for f.pending == "" {
+		if err := f.find(); err != nil {
+			return nil, err
+		}
+		if f.pending != "" {
+			break
+		}
+		if err := f.watch.wait(f.ctx, f.current); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.Open(f.pending)
+	if err != nil {
+		return nil, err
+	}
+	t := tail{
+		follower: f,
+		file:     file,
+		name:     f.pending,
+	}
+	r := File{
+		Reader: &t,
+		name:   t.name,
+		inner:  file,
+	}
+	if f.current == "" {
+		if f.cfg.from.IsZero() {
+			err = t.skip(&r)
+		} else {
+			err = f.cfg.seek(&r)
+			if err == io.EOF {
+				err = nil
+			}
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	f.current, f.pending = f.pending, ""
+	f.starts, f.ends, _ = f.cfg.layout.Span(f.base, f.current)
+	return &r, nil
+}
+
+// find looks for the file following the current one or, for the first file,
+// the latest file of the archive (or the first one after the time given with
+// StartAt).
+//
+// The file following the current one is usually the file of the next interval
+// of the layout. The archive is only walked when this file does not exist,
+// since intervals without packets have no file, and at most once a minute.
+func (f *follower) find() error {
+	var (
+		from  = f.starts
+		first = f.current == ""
+	)
+	if first {
+		from = f.cfg.from
+	} else {
+		if !f.ends.IsZero() {
+			p := f.cfg.layout.Path(f.base, PacketInfo{When: f.ends})
+			if i, err := os.Stat(p); err == nil && i.Mode().IsRegular() {
+				f.pending = p
+				return nil
+			}
+		}
+		every := f.cfg.layout.Interval
+		if every > time.Minute {
+			every = time.Minute
+		}
+		if time.Since(f.walked) < every {
+			return f.ctx.Err()
+		}
+		f.walked = time.Now()
+	}
+	ctx, cancel := context.WithCancel(f.ctx)
+	defer cancel()
+	var (
+		file string
+		when time.Time
+	)
+	for e := range walkRange(ctx, f.base, from, time.Time{}, f.cfg) {
+		if e.err != nil || TrimCodec(e.file) != e.file || e.file == f.current {
+			continue
+		}
+		s, _, err := f.cfg.layout.Span(f.base, e.file)
+		if err != nil || (!first && !s.After(f.starts)) {
+			continue
+		}
+		latest := first && f.cfg.from.IsZero()
+		if file == "" || (latest && !s.Before(when)) || (!latest && s.Before(when)) {
+			file, when = e.file, s
+		}
+	}
+	f.pending = file
+	return f.ctx.Err()
+}
+
+func (f *follower) Close() error {
+	return f.watch.close()
+}
+
+// tail reads a file being written. It only gives complete frames and returns
+// io.EOF once the follower found the next file and the file stopped growing.
+type tail struct {
+	*follower
+	file  *os.File
+	name  string
+	buf   []byte
+	chunk []byte
+	ready int
+	last  time.Time
+}
+
+func (t *tail) Read(xs []byte) (int, error) {
+	if t.chunk == nil {
+		t.chunk = make([]byte, 64<<10)
+	}
+	for {
+		if t.ready > 0 {
+			n := copy(xs, t.buf[:t.ready])
+			t.buf = t.buf[:copy(t.buf, t.buf[n:])]
+			t.ready -= n
+			return n, nil
+		}
+		n, err := t.file.Read(t.chunk)
+		if n > 0 {
+			t.buf = append(t.buf, t.chunk[:n]...)
+			t.ready = complete(t.buf)
+			t.last = time.Now()
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		if t.pending == "" {
+			if err := t.find(); err != nil {
+				return 0, err
+			}
+			if t.pending != "" {
+				// data written before the next file was created may not
+				// have been read yet
+				t.last = time.Now()
+				continue
+			}
+		}
+		if t.pending != "" && time.Since(t.last) >= t.cfg.drain {
+			if len(t.buf) > 0 && t.cfg.skip != nil {
+				t.cfg.skip(t.name, TruncatedError(len(t.buf)))
+			}
+			return 0, io.EOF
+		}
+		if err := t.watch.wait(t.ctx, t.name); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// skip moves f to the end of the last complete frame of the file.
+func (t *tail) skip(f *File) error {
+	var (
+		chunk = make([]byte, 64<<10)
+		pos   int64
+	)
+	for {
+		n, err := t.file.Read(chunk)
+		t.buf = append(t.buf, chunk[:n]...)
+		if c := complete(t.buf); c > 0 {
+			pos += int64(c)
+			t.buf = t.buf[:copy(t.buf, t.buf[c:])]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	f.pos = pos
+	return nil
+}
+
+// complete gives the number of bytes of the complete frames at the start of
+// bs. A length prefix greater than MaxPacketSize can not be trusted; all the
+// bytes are then given and left to the Reader to resynchronize.
+func complete(bs []byte) int {
+	var n int
+	for len(bs)-n >= 4 {
+		size := int(binary.LittleEndian.Uint32(bs[n:]))
+		if size > MaxPacketSize {
+			return len(bs)
+		}
+		if n+size+4 > len(bs) {
+			break
+		}
+		n += size + 4
+	}
+	return n
+}
+
+// watcher waits for changes in an archive.
+type watcher interface {
+	wait(ctx context.Context, file string) error
+	close() error
+}
+
+type poller time.Duration
+
+func (p poller) wait(ctx context.Context, _ string) error {
+	t := time.NewTimer(time.Duration(p))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p poller) close() error {
+	return nil
+}
@@ -0,0 +1,93 @@
+package rt
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_MOVED_TO | syscall.IN_CLOSE_WRITE
+
+// inotify wakes up when the directories between the base of the archive and
+// the current file change, and at least every timeout otherwise since new
+// directories of the archive are not watched before they are walked.
+type inotify struct {
+	base    string
+	file    *os.File
+	timeout time.Duration
+	watched map[string]struct{}
+	buf     []byte
+}
+
+func newWatcher(base string, timeout time.Duration) watcher {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return poller(timeout)
+	}
+	i := inotify{
+		base:    filepath.Clean(base),
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		timeout: timeout,
+		watched: make(map[string]struct{}),
+		buf:     make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1)),
+	}
+	return &i
+}
+
+func (i *inotify) wait(ctx context.Context, file string) error {
+	if i.file == nil {
+		return poller(i.timeout).wait(ctx, file)
+	}
+	dir := i.base
+	if file != "" {
+		dir = filepath.Dir(file)
+	}
+	for {
+		i.add(dir)
+		if dir == i.base || !strings.HasPrefix(dir, i.base) {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	// the deadline is short to notice a cancellation of ctx
+	deadline := time.Now().Add(i.timeout)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		step := time.Now().Add(100 * time.Millisecond)
+		if step.After(deadline) {
+			step = deadline
+		}
+		i.file.SetReadDeadline(step)
+		_, err := i.file.Read(i.buf)
+		if err == nil || !os.IsTimeout(err) {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return nil
+		}
+	}
+}
+
+func (i *inotify) add(dir string) {
+	if _, ok := i.watched[dir]; ok {
+		return
+	}
+	fd := int(i.file.Fd())
+	if _, err := syscall.InotifyAddWatch(fd, dir, inotifyMask); err == nil {
+		i.watched[dir] = struct{}{}
+	}
+}
+
+func (i *inotify) close() error {
+	if i.file == nil {
+		return nil
+	}
+	err := i.file.Close()
+	i.file = nil
+	return err
+}
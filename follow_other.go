@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package rt
+
+import (
+	"time"
+)
+
+func newWatcher(_ string, timeout time.Duration) watcher {
+	return poller(timeout)
+}
@@ -0,0 +1,70 @@
+package rt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFollowRotate(t *testing.T) {
+	var (
+		base = t.TempDir()
+		day  = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	)
+	a, err := NewArchiveWriter(base, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	write := func(m int, p string) {
+		t.Helper()
+		if err := a.WritePacket(PacketInfo{When: day.Add(time.Duration(m) * time.Minute)}, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(0, "p0")
+	write(1, "p1")
+
+	r, err := Follow(base, nil, StartAt(day), WithPolling(10*time.Millisecond), WithDrain(500*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		sc = NewScanner(r)
+		ps = make(chan string)
+	)
+	go func() {
+		defer close(ps)
+		for sc.Scan() {
+			ps <- string(sc.Packet().Payload)
+		}
+	}()
+	defer func() {
+		r.Close()
+		for range ps {
+		}
+	}()
+	expect := func(want ...string) {
+		t.Helper()
+		for _, w := range want {
+			select {
+			case p, ok := <-ps:
+				if !ok {
+					t.Fatalf("stream stopped, want %s (%v)", w, sc.Err())
+				}
+				if p != w {
+					t.Fatalf("got %s, want %s", p, w)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timeout waiting for %s", w)
+			}
+		}
+	}
+	expect("p0", "p1")
+
+	// p3 is written in the file of p0 and p1 after the file of p2 exists
+	write(6, "p2")
+	time.Sleep(100 * time.Millisecond)
+	write(2, "p3")
+	write(7, "p4")
+	expect("p3", "p2", "p4")
+}
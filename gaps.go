@@ -0,0 +1,404 @@
+package rt
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	defaultCounter = 32
+	silenceSamples = 64
+	silenceLearn   = 8
+)
+
+// Analyzer checks the completeness of a packet stream. It follows the
+// sequence counters of the packets of each pid and gives the totals per pid
+// and the gaps found between consecutive packets.
+//
+// Counters are compared modulo their width, so that a counter can wrap. Each
+// discontinuity of a counter is classified as:
+//   - a gap when the counter jumped forward,
+//   - a duplicate when the counter did not change,
+//   - a reorder when the counter went backward and the packet is not more
+//     recent than the previous one (a late packet); the packet is then removed
+//     from the gap where it was counted as missing,
+//   - a reset when the counter went backward while the time went forward, or
+//     when it jumped forward through its maximum faster than the usual rate of
+//     the pid allows (eg: the software generating the packets restarted).
+//
+// The packets of the pids given to SetPeriod have no usable counter; a gap is
+// found when such a pid is silent for longer than its period.
+type Analyzer struct {
+	decoder HeaderDecoder
+	stats   map[int]*Coze
+	last    map[int]PacketInfo
+	period  map[int]time.Duration
+	gaps    []Gap
+	invalid uint64
+
+	bits     map[int]int
+	dflt     int
+	silences map[int]*silence
+
+	bucket  time.Duration
+	buckets map[bucketKey]*Coze
+}
+
+type bucketKey struct {
+	Pid  int
+	When int64
+}
+
+// Bucket gives the counters of a pid for the interval starting at Time.
+type Bucket struct {
+	Time time.Time `json:"bucket"`
+	Coze
+}
+
+// silence keeps the expected period of a pid and its last intervals when the
+// period has to be learned.
+type silence struct {
+	period    time.Duration
+	intervals []time.Duration
+	next      int
+}
+
+// NewAnalyzer creates an Analyzer decoding the payloads given to Push with d.
+// When d is a CounterDecoder, the width of its counters is the default of the
+// Analyzer (see SetCounter).
+func NewAnalyzer(d HeaderDecoder) *Analyzer {
+	a := Analyzer{
+		decoder:  d,
+		stats:    make(map[int]*Coze),
+		last:     make(map[int]PacketInfo),
+		period:   make(map[int]time.Duration),
+		bits:     make(map[int]int),
+		dflt:     defaultCounter,
+		silences: make(map[int]*silence),
+		buckets:  make(map[bucketKey]*Coze),
+	}
+	if c, ok := d.(CounterDecoder); ok {
+		a.SetCounter(c.CounterBits())
+	}
+	return &a
+}
+
+// SetBucket makes the Analyzer also count the packets of each pid per interval
+// of the given size (eg: 5 minutes), according to the time of the packets. The
+// packets lost in a gap are counted in the interval of the packet that follows
+// the gap.
+func (a *Analyzer) SetBucket(size time.Duration) {
+	if size > 0 {
+		a.bucket = size
+	}
+}
+
+// SetPeriod makes the Analyzer ignore the sequence counter of the packets of
+// the given pid and look for the periods where the pid was silent instead. A
+// period is silent when the interval between two packets is at least one and
+// a half times the expected period; the number of missing packets is then
+// estimated from the interval. When period is 0, the expected period is the
+// median of the last intervals between the packets of the pid.
+func (a *Analyzer) SetPeriod(pid int, period time.Duration) {
+	a.silences[pid] = &silence{period: period}
+}
+
+// SetCounter gives the width in bits of the sequence counter of the packets
+// (32 bits by default, unless given by the decoder).
+func (a *Analyzer) SetCounter(bits int) {
+	if bits > 0 && bits <= 32 {
+		a.dflt = bits
+	}
+}
+
+// SetPidCounter gives the width in bits of the sequence counter of the packets
+// of the given pid.
+func (a *Analyzer) SetPidCounter(pid, bits int) {
+	if bits > 0 && bits <= 32 {
+		a.bits[pid] = bits
+	}
+}
+
+func (a *Analyzer) counter(pid int) int {
+	if b, ok := a.bits[pid]; ok {
+		return b
+	}
+	return a.dflt
+}
+
+// Push decodes the given payload and updates the counters of its pid.
+func (a *Analyzer) Push(bs []byte) error {
+	i, err := a.decoder.Decode(bs)
+	if err != nil {
+		a.invalid++
+		return err
+	}
+	a.Add(i, len(bs))
+	return nil
+}
+
+// Add updates the counters of the pid of an already decoded packet of the
+// given size.
+func (a *Analyzer) Add(i PacketInfo, size int) {
+	d := Coze{
+		Count: 1,
+		Size:  uint64(size),
+	}
+	a.check(&d, i)
+
+	c, ok := a.stats[i.Pid]
+	if !ok {
+		c = &Coze{Id: i.Pid}
+		a.stats[i.Pid] = c
+	}
+	record(c, i, &d)
+	if a.bucket > 0 {
+		k := bucketKey{Pid: i.Pid, When: a.bucketOf(i.When)}
+		c, ok := a.buckets[k]
+		if !ok {
+			c = &Coze{Id: i.Pid}
+			a.buckets[k] = c
+		}
+		record(c, i, &d)
+	}
+}
+
+// bucketOf gives the start of the interval of t when counting per interval.
+func (a *Analyzer) bucketOf(t time.Time) int64 {
+	if a.bucket <= 0 {
+		return 0
+	}
+	return t.Truncate(a.bucket).UnixNano()
+}
+
+// record adds the counters of a packet to c.
+func record(c *Coze, i PacketInfo, d *Coze) {
+	if c.Count == 0 {
+		c.First = uint64(i.Sequence)
+	}
+	c.Last = uint64(i.Sequence)
+	if c.StartTime.IsZero() || i.When.Before(c.StartTime) {
+		c.StartTime = i.When
+	}
+	if i.When.After(c.EndTime) {
+		c.EndTime = i.When
+	}
+	c.Update(d)
+}
+
+// check compares a packet with the previous packet of its pid and sets the
+// counters of the discontinuity found between them in c.
+func (a *Analyzer) check(c *Coze, i PacketInfo) {
+	p, ok := a.last[i.Pid]
+	a.last[i.Pid] = i
+	if !ok {
+		return
+	}
+	if s, ok := a.silences[i.Pid]; ok {
+		a.silent(c, s, p, i)
+		return
+	}
+	var (
+		bits = a.counter(i.Pid)
+		m    = uint64(1) << uint(bits)
+		d    = (uint64(i.Sequence) - uint64(p.Sequence)) % m
+		dt   = i.When.Sub(p.When)
+	)
+	switch {
+	case d == 0:
+		c.Duplicate++
+		c.Error++
+		a.last[i.Pid] = p
+	case d >= m/2 && !i.When.After(p.When):
+		c.Reorder++
+		c.Error++
+		a.last[i.Pid] = p
+		a.fill(i, m)
+	case d >= m/2 || (i.Sequence < p.Sequence && a.tooFast(i.Pid, d, dt)):
+		c.Restart++
+		c.Error++
+	case d > 1:
+		g := Gap{
+			Id:     i.Pid,
+			Starts: p.When,
+			Ends:   i.When,
+			Last:   int(p.Sequence),
+			First:  int(i.Sequence),
+			Bits:   bits,
+			bucket: a.bucketOf(i.When),
+		}
+		c.Missing += uint64(g.Missing())
+		a.gaps = append(a.gaps, g)
+	default:
+		a.learn(i.Pid, dt)
+	}
+}
+
+// fill removes the counter of a late packet from the gap where it was counted
+// as missing, if any. The gap is shrunk or split in two gaps around the packet.
+func (a *Analyzer) fill(i PacketInfo, m uint64) {
+	for j := len(a.gaps) - 1; j >= 0; j-- {
+		g := a.gaps[j]
+		if g.Id != i.Pid || g.Estimated > 0 {
+			continue
+		}
+		var (
+			d = (uint64(i.Sequence) - uint64(g.Last)) % m
+			n = (uint64(g.First) - uint64(g.Last)) % m
+		)
+		if d == 0 || d >= n {
+			continue
+		}
+		var (
+			before = g
+			after  = g
+			gs     []Gap
+		)
+		before.First, before.Ends = int(i.Sequence), i.When
+		after.Last, after.Starts = int(i.Sequence), i.When
+		if before.Missing() > 0 {
+			gs = append(gs, before)
+		}
+		if after.Missing() > 0 {
+			gs = append(gs, after)
+		}
+		a.gaps = append(a.gaps[:j], append(gs, a.gaps[j+1:]...)...)
+
+		a.stats[g.Id].Missing--
+		if a.bucket > 0 {
+			k := bucketKey{Pid: g.Id, When: g.bucket}
+			if c, ok := a.buckets[k]; ok {
+				c.Missing--
+			}
+		}
+		return
+	}
+}
+
+func (a *Analyzer) silent(c *Coze, s *silence, p, i PacketInfo) {
+	dt := i.When.Sub(p.When)
+	if dt <= 0 {
+		a.last[i.Pid] = p
+		return
+	}
+	period := s.period
+	if period <= 0 {
+		period = s.median()
+		s.add(dt)
+	}
+	if period <= 0 {
+		return
+	}
+	n := int(math.Round(float64(dt)/float64(period))) - 1
+	if n < 1 {
+		return
+	}
+	g := Gap{
+		Id:        i.Pid,
+		Starts:    p.When,
+		Ends:      i.When,
+		Last:      int(p.Sequence),
+		First:     int(i.Sequence),
+		Estimated: n,
+		bucket:    a.bucketOf(i.When),
+	}
+	c.Missing += uint64(n)
+	a.gaps = append(a.gaps, g)
+}
+
+func (s *silence) add(dt time.Duration) {
+	if len(s.intervals) < silenceSamples {
+		s.intervals = append(s.intervals, dt)
+		return
+	}
+	s.intervals[s.next] = dt
+	s.next = (s.next + 1) % silenceSamples
+}
+
+// median gives the median of the last intervals or 0 when there are not enough
+// of them yet.
+func (s *silence) median() time.Duration {
+	if len(s.intervals) < silenceLearn {
+		return 0
+	}
+	ds := append([]time.Duration(nil), s.intervals...)
+	sort.Slice(ds, func(i, j int) bool {
+		return ds[i] < ds[j]
+	})
+	return ds[len(ds)/2]
+}
+
+// learn updates the usual interval between two consecutive packets of pid.
+func (a *Analyzer) learn(pid int, dt time.Duration) {
+	if dt <= 0 {
+		return
+	}
+	if p, ok := a.period[pid]; ok {
+		dt = (p*7 + dt) / 8
+	}
+	a.period[pid] = dt
+}
+
+// tooFast reports whether d packets of pid can not have been sent in dt, even
+// at twice their usual rate.
+func (a *Analyzer) tooFast(pid int, d uint64, dt time.Duration) bool {
+	p, ok := a.period[pid]
+	if !ok {
+		return false
+	}
+	return time.Duration(d)*p/2 > dt
+}
+
+// Stats gives the counters of each pid sorted by pid: the number of packets
+// (Count) and their size, the number of missing packets (Missing), the number
+// of duplicates, reorders and resets (Restart) and their sum (Error), the first and last
+// sequence counters and the time span of the packets.
+func (a *Analyzer) Stats() []Coze {
+	cs := make([]Coze, 0, len(a.stats))
+	for _, c := range a.stats {
+		cs = append(cs, *c)
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		return cs[i].Id < cs[j].Id
+	})
+	return cs
+}
+
+// Buckets gives the counters per pid and per interval (see SetBucket), sorted
+// by time and pid.
+func (a *Analyzer) Buckets() []Bucket {
+	bs := make([]Bucket, 0, len(a.buckets))
+	for k, c := range a.buckets {
+		bs = append(bs, Bucket{Time: time.Unix(0, k.When).UTC(), Coze: *c})
+	}
+	sort.Slice(bs, func(i, j int) bool {
+		if bs[i].Time.Equal(bs[j].Time) {
+			return bs[i].Id < bs[j].Id
+		}
+		return bs[i].Time.Before(bs[j].Time)
+	})
+	return bs
+}
+
+// Total gives the counters of all the pids together. Packets that could not be
+// decoded are counted in Error.
+func (a *Analyzer) Total() Coze {
+	var t Coze
+	for _, c := range a.stats {
+		t.Update(c)
+		if t.StartTime.IsZero() || c.StartTime.Before(t.StartTime) {
+			t.StartTime = c.StartTime
+		}
+		if c.EndTime.After(t.EndTime) {
+			t.EndTime = c.EndTime
+		}
+	}
+	t.Error += a.invalid
+	return t
+}
+
+// Gaps gives the gaps found so far in the order they were found.
+func (a *Analyzer) Gaps() []Gap {
+	return a.gaps
+}
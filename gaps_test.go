@@ -0,0 +1,274 @@
+package rt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAnalyzerCheck(t *testing.T) {
+	type packet struct {
+		Seq uint
+		Sec int
+	}
+	data := []struct {
+		Name      string
+		Bits      int
+		Packets   []packet
+		Missing   uint64
+		Duplicate uint64
+		Reorder   uint64
+		Restart   uint64
+		Gaps      [][2]int
+	}{
+		{
+			Name:    "continuous",
+			Packets: []packet{{1, 1}, {2, 2}, {3, 3}},
+		},
+		{
+			Name:    "gap",
+			Packets: []packet{{1, 1}, {2, 2}, {5, 5}},
+			Missing: 2,
+			Gaps:    [][2]int{{2, 5}},
+		},
+		{
+			Name:    "wrap",
+			Bits:    4,
+			Packets: []packet{{14, 1}, {15, 2}, {0, 3}, {1, 4}},
+		},
+		{
+			Name:    "gap through wrap",
+			Bits:    4,
+			Packets: []packet{{14, 1}, {1, 4}},
+			Missing: 2,
+			Gaps:    [][2]int{{14, 1}},
+		},
+		{
+			Name:      "duplicate",
+			Packets:   []packet{{1, 1}, {2, 2}, {2, 2}, {3, 3}},
+			Duplicate: 1,
+		},
+		{
+			Name:    "reorder",
+			Packets: []packet{{1, 1}, {3, 3}, {2, 2}, {4, 4}, {5, 5}},
+			Reorder: 1,
+		},
+		{
+			Name:    "reorder at the start of a gap",
+			Packets: []packet{{1, 1}, {4, 4}, {2, 2}, {5, 5}},
+			Missing: 1,
+			Reorder: 1,
+			Gaps:    [][2]int{{2, 4}},
+		},
+		{
+			Name:    "reorder in a gap",
+			Packets: []packet{{1, 1}, {5, 5}, {3, 3}, {6, 6}},
+			Missing: 2,
+			Reorder: 1,
+			Gaps:    [][2]int{{1, 3}, {3, 5}},
+		},
+		{
+			Name:    "reorder in a wrapped gap",
+			Bits:    4,
+			Packets: []packet{{14, 1}, {1, 4}, {15, 2}, {0, 3}},
+			Reorder: 2,
+		},
+		{
+			Name:    "reorder outside gaps",
+			Packets: []packet{{1, 1}, {2, 2}, {5, 5}, {1, 1}},
+			Missing: 2,
+			Reorder: 1,
+			Gaps:    [][2]int{{2, 5}},
+		},
+		{
+			Name:    "reset",
+			Packets: []packet{{100, 1}, {101, 2}, {102, 3}, {1, 4}, {2, 5}},
+			Restart: 1,
+		},
+		{
+			Name:    "reset through wrap",
+			Bits:    8,
+			Packets: []packet{{10, 10}, {11, 20}, {12, 30}, {13, 40}, {3, 50}},
+			Restart: 1,
+		},
+	}
+	base := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, d := range data {
+		a := NewAnalyzer(nil)
+		a.SetCounter(d.Bits)
+		for _, p := range d.Packets {
+			a.Add(PacketInfo{Pid: 1, Sequence: p.Seq, When: base.Add(time.Duration(p.Sec) * time.Second)}, 10)
+		}
+		c := a.Total()
+		if c.Count != uint64(len(d.Packets)) {
+			t.Errorf("%s: expected %d packets, got %d", d.Name, len(d.Packets), c.Count)
+		}
+		if c.Missing != d.Missing || c.Duplicate != d.Duplicate || c.Reorder != d.Reorder || c.Restart != d.Restart {
+			t.Errorf("%s: expected missing=%d, duplicate=%d, reorder=%d, restart=%d, got %d, %d, %d, %d", d.Name, d.Missing, d.Duplicate, d.Reorder, d.Restart, c.Missing, c.Duplicate, c.Reorder, c.Restart)
+		}
+		gs := a.Gaps()
+		if len(gs) != len(d.Gaps) {
+			t.Errorf("%s: expected %d gaps, got %d (%+v)", d.Name, len(d.Gaps), len(gs), gs)
+			continue
+		}
+		var missing int
+		for i, g := range gs {
+			if g.Last != d.Gaps[i][0] || g.First != d.Gaps[i][1] {
+				t.Errorf("%s: gap %d: expected %d-%d, got %d-%d", d.Name, i, d.Gaps[i][0], d.Gaps[i][1], g.Last, g.First)
+			}
+			missing += g.Missing()
+		}
+		if uint64(missing) != c.Missing {
+			t.Errorf("%s: %d packets missing in gaps, %d counted", d.Name, missing, c.Missing)
+		}
+	}
+}
+
+func TestAnalyzerBuckets(t *testing.T) {
+	type packet struct {
+		Seq uint
+		Sec int
+	}
+	type bucket struct {
+		Min     int
+		Count   uint64
+		Missing uint64
+		Reorder uint64
+	}
+	data := []struct {
+		Name    string
+		Packets []packet
+		Buckets []bucket
+	}{
+		{
+			Name:    "continuous",
+			Packets: []packet{{1, 10}, {2, 50}, {3, 70}, {4, 130}},
+			Buckets: []bucket{{Min: 0, Count: 2}, {Min: 1, Count: 1}, {Min: 2, Count: 1}},
+		},
+		{
+			Name:    "gap",
+			Packets: []packet{{1, 10}, {2, 20}, {6, 130}, {7, 140}},
+			Buckets: []bucket{{Min: 0, Count: 2}, {Min: 2, Count: 2, Missing: 3}},
+		},
+		{
+			// the gap 2-9 is counted in the bucket of 9 and the late
+			// packets in their own bucket
+			Name:    "late packets in a gap",
+			Packets: []packet{{1, 10}, {2, 20}, {9, 130}, {5, 50}, {3, 30}},
+			Buckets: []bucket{{Min: 0, Count: 4, Reorder: 2}, {Min: 2, Count: 1, Missing: 4}},
+		},
+	}
+	base := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, d := range data {
+		a := NewAnalyzer(nil)
+		a.SetBucket(time.Minute)
+		for _, p := range d.Packets {
+			a.Add(PacketInfo{Pid: 1, Sequence: p.Seq, When: base.Add(time.Duration(p.Sec) * time.Second)}, 10)
+		}
+		bs := a.Buckets()
+		if len(bs) != len(d.Buckets) {
+			t.Errorf("%s: expected %d buckets, got %d (%+v)", d.Name, len(d.Buckets), len(bs), bs)
+			continue
+		}
+		var missing uint64
+		for i, b := range bs {
+			w := d.Buckets[i]
+			if when := base.Add(time.Duration(w.Min) * time.Minute); !b.Time.Equal(when) {
+				t.Errorf("%s: bucket %d: expected %s, got %s", d.Name, i, when, b.Time)
+			}
+			if b.Count != w.Count || b.Missing != w.Missing || b.Reorder != w.Reorder {
+				t.Errorf("%s: bucket %d: expected count=%d, missing=%d, reorder=%d, got %d, %d, %d", d.Name, i, w.Count, w.Missing, w.Reorder, b.Count, b.Missing, b.Reorder)
+			}
+			missing += b.Missing
+		}
+		if c := a.Total(); c.Missing != missing {
+			t.Errorf("%s: %d packets missing in buckets, %d counted", d.Name, missing, c.Missing)
+		}
+	}
+}
+
+func TestAnalyzerSilence(t *testing.T) {
+	every := func(n, step int) []int {
+		ss := make([]int, n)
+		for i := range ss {
+			ss[i] = i * step
+		}
+		return ss
+	}
+	data := []struct {
+		Name    string
+		Period  time.Duration
+		Seconds []int
+		Missing []int
+	}{
+		{
+			Name:    "regular",
+			Period:  10 * time.Second,
+			Seconds: every(5, 10),
+		},
+		{
+			Name:    "jitter",
+			Period:  10 * time.Second,
+			Seconds: []int{0, 10, 24, 30, 40},
+		},
+		{
+			Name:    "silent",
+			Period:  10 * time.Second,
+			Seconds: []int{0, 10, 20, 50, 60},
+			Missing: []int{2},
+		},
+		{
+			Name:    "learned",
+			Seconds: append(every(10, 10), 130, 140),
+			Missing: []int{3},
+		},
+		{
+			Name:    "not learned yet",
+			Seconds: []int{0, 10, 20, 60, 70},
+		},
+	}
+	base := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, d := range data {
+		a := NewAnalyzer(nil)
+		a.SetPeriod(1, d.Period)
+		for _, s := range d.Seconds {
+			// without counter, the sequence is not used
+			a.Add(PacketInfo{Pid: 1, When: base.Add(time.Duration(s) * time.Second)}, 10)
+		}
+		gs := a.Gaps()
+		if len(gs) != len(d.Missing) {
+			t.Errorf("%s: expected %d gaps, got %d (%+v)", d.Name, len(d.Missing), len(gs), gs)
+			continue
+		}
+		for i, g := range gs {
+			if g.Missing() != d.Missing[i] || g.Estimated == 0 {
+				t.Errorf("%s: gap %d: expected %d missing, got %d (estimated %d)", d.Name, i, d.Missing[i], g.Missing(), g.Estimated)
+			}
+		}
+		if c := a.Total(); c.Duplicate != 0 || c.Restart != 0 {
+			t.Errorf("%s: unexpected errors: duplicate=%d, restart=%d", d.Name, c.Duplicate, c.Restart)
+		}
+	}
+}
+
+type counterDecoder int
+
+func (c counterDecoder) Decode([]byte) (PacketInfo, error) {
+	return PacketInfo{}, ErrInvalid
+}
+
+func (c counterDecoder) CounterBits() int {
+	return int(c)
+}
+
+func TestAnalyzerDecoderCounter(t *testing.T) {
+	var (
+		a    = NewAnalyzer(counterDecoder(14))
+		base = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	)
+	for i, seq := range []uint{16382, 16383, 0, 1} {
+		a.Add(PacketInfo{Pid: 1, Sequence: seq, When: base.Add(time.Duration(i) * time.Second)}, 10)
+	}
+	if c := a.Total(); c.Restart != 0 || c.Missing != 0 {
+		t.Errorf("14 bits counter wrap: got restart=%d, missing=%d", c.Restart, c.Missing)
+	}
+}
@@ -0,0 +1,427 @@
+package rt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// IndexExt is appended to the name of a file to give the name of its index.
+const IndexExt = ".idx"
+
+var ErrStale = errors.New("rt: index out of date")
+
+var indexMagic = [4]byte{'R', 'T', 'I', 'X'}
+
+const (
+	indexVersion    = 2
+	indexHeaderLen  = 24
+	indexEntryLen   = 32
+	indexHasHeaders = 1 << 0
+	indexDecoded    = 1 << 0
+
+	// entries of the first version had no flags
+	indexEntryV1Len = 28
+)
+
+// IndexEntry locates a packet in an rt file. Offset is the position of its
+// length prefix in the decompressed content of the file and Length the size of
+// its payload. Decoded is set when the index was built with a HeaderDecoder
+// able to decode the packet; Pid, Sequence and When are then the fields given
+// by the decoder, possibly zero.
+type IndexEntry struct {
+	Offset   int64
+	Length   int
+	Pid      int
+	Sequence uint
+	When     time.Time
+	Decoded  bool
+}
+
+// Index lists the packets of an rt file. It is stored next to the file, in a
+// sidecar named after it (see IndexPath), and records the size and the
+// modification time of the file it was built from to detect changes.
+type Index struct {
+	Size    int64
+	ModTime time.Time
+	Headers bool
+	Entries []IndexEntry
+}
+
+func IndexPath(file string) string {
+	return file + IndexExt
+}
+
+// BuildIndex reads file and lists its packets. When d is not nil, it is used to
+// decode the headers of the packets.
+func BuildIndex(file string, d HeaderDecoder) (*Index, error) {
+	i, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	x := Index{
+		Size:    i.Size(),
+		ModTime: i.ModTime(),
+		Headers: d != nil,
+	}
+	if err := x.scan(file, 0, d); err != nil {
+		return nil, err
+	}
+	return &x, nil
+}
+
+// IndexFile builds the index of file or refreshes its existing index and
+// writes it on disk. An index is rebuilt when it is out of date, unless the
+// file is not compressed and has only grown, in which case only the new
+// packets are added. It reports whether the index was written.
+func IndexFile(file string, d HeaderDecoder) (*Index, bool, error) {
+	i, err := os.Stat(file)
+	if err != nil {
+		return nil, false, err
+	}
+	x, err := ReadIndex(IndexPath(file))
+	switch {
+	case err != nil:
+		x, err = BuildIndex(file, d)
+	case x.current(i) && (d == nil || x.Headers):
+		return x, false, nil
+	case x.Headers == (d != nil) && x.Size < i.Size() && !x.ModTime.After(i.ModTime()) && TrimCodec(file) == file:
+		x.Size, x.ModTime = i.Size(), i.ModTime()
+		err = x.scan(file, x.end(), d)
+	default:
+		x, err = BuildIndex(file, d)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if err := x.writeFile(IndexPath(file)); err != nil {
+		return nil, false, err
+	}
+	return x, true, nil
+}
+
+// LoadIndex reads the index of file. It fails with ErrStale when file was
+// modified since its index was built.
+func LoadIndex(file string) (*Index, error) {
+	return loadIndex(nil, file)
+}
+
+func loadIndex(fsys fs.FS, file string) (*Index, error) {
+	var (
+		r   io.ReadCloser
+		i   fs.FileInfo
+		err error
+	)
+	if fsys == nil {
+		r, err = os.Open(IndexPath(file))
+	} else {
+		r, err = fsys.Open(IndexPath(file))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if fsys == nil {
+		i, err = os.Stat(file)
+	} else {
+		i, err = fs.Stat(fsys, file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	x, err := ReadIndexFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	if !x.current(i) {
+		return nil, fmt.Errorf("%s: %w", file, ErrStale)
+	}
+	return x, nil
+}
+
+// ReadIndex reads an index from the given file, without checking whether it is
+// still up to date.
+func ReadIndex(file string) (*Index, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ReadIndexFrom(r)
+}
+
+func ReadIndexFrom(r io.Reader) (*Index, error) {
+	rs := bufio.NewReader(r)
+
+	var h [indexHeaderLen]byte
+	if _, err := io.ReadFull(rs, h[:]); err != nil {
+		return nil, fmt.Errorf("index: %w", err)
+	}
+	if string(h[:4]) != string(indexMagic[:]) {
+		return nil, fmt.Errorf("index: bad magic %q", h[:4])
+	}
+	size := indexEntryLen
+	switch v := binary.LittleEndian.Uint16(h[4:]); v {
+	case indexVersion:
+	case 1:
+		size = indexEntryV1Len
+	default:
+		return nil, fmt.Errorf("index: unsupported version %d", v)
+	}
+	x := Index{
+		Headers: binary.LittleEndian.Uint16(h[6:])&indexHasHeaders != 0,
+		Size:    int64(binary.LittleEndian.Uint64(h[8:])),
+		ModTime: unixTime(int64(binary.LittleEndian.Uint64(h[16:]))),
+	}
+	var e [indexEntryLen]byte
+	for {
+		if _, err := io.ReadFull(rs, e[:size]); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("index: %w", err)
+		}
+		i := IndexEntry{
+			Offset:   int64(binary.LittleEndian.Uint64(e[0:])),
+			Length:   int(binary.LittleEndian.Uint32(e[8:])),
+			Pid:      int(binary.LittleEndian.Uint32(e[12:])),
+			Sequence: uint(binary.LittleEndian.Uint32(e[16:])),
+			When:     unixTime(int64(binary.LittleEndian.Uint64(e[20:]))),
+		}
+		if size == indexEntryV1Len {
+			i.Decoded = x.Headers && !i.When.IsZero()
+		} else {
+			i.Decoded = binary.LittleEndian.Uint32(e[28:])&indexDecoded != 0
+		}
+		x.Entries = append(x.Entries, i)
+	}
+	return &x, nil
+}
+
+func (x *Index) WriteTo(w io.Writer) (int64, error) {
+	ws := bufio.NewWriter(w)
+
+	var h [indexHeaderLen]byte
+	copy(h[:], indexMagic[:])
+	binary.LittleEndian.PutUint16(h[4:], indexVersion)
+	if x.Headers {
+		binary.LittleEndian.PutUint16(h[6:], indexHasHeaders)
+	}
+	binary.LittleEndian.PutUint64(h[8:], uint64(x.Size))
+	binary.LittleEndian.PutUint64(h[16:], uint64(unixNano(x.ModTime)))
+	ws.Write(h[:])
+
+	var e [indexEntryLen]byte
+	for _, i := range x.Entries {
+		binary.LittleEndian.PutUint64(e[0:], uint64(i.Offset))
+		binary.LittleEndian.PutUint32(e[8:], uint32(i.Length))
+		binary.LittleEndian.PutUint32(e[12:], uint32(i.Pid))
+		binary.LittleEndian.PutUint32(e[16:], uint32(i.Sequence))
+		binary.LittleEndian.PutUint64(e[20:], uint64(unixNano(i.When)))
+		var flags uint32
+		if i.Decoded {
+			flags |= indexDecoded
+		}
+		binary.LittleEndian.PutUint32(e[28:], flags)
+		ws.Write(e[:])
+	}
+	n := int64(indexHeaderLen + len(x.Entries)*indexEntryLen)
+	if err := ws.Flush(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// SearchTime gives the position in the index of the first packet whose time is
+// not before t or -1 if there is none.
+func (x *Index) SearchTime(t time.Time) int {
+	for i, e := range x.Entries {
+		if !e.When.IsZero() && !e.When.Before(t) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SearchPacket gives the position in the index of the first packet with the
+// given pid and sequence counter or -1 if there is none.
+func (x *Index) SearchPacket(pid int, seq uint) int {
+	for i, e := range x.Entries {
+		if e.Decoded && e.Pid == pid && e.Sequence == seq {
+			return i
+		}
+	}
+	return -1
+}
+
+// SeekTime moves f to the first packet of its index x whose time is not before
+// t and gives its position in x. It returns io.EOF if there is no such packet.
+func (f *File) SeekTime(x *Index, t time.Time) (int, error) {
+	return f.seekEntry(x, x.SearchTime(t))
+}
+
+// SeekPacket moves f to the first packet of its index x with the given pid and
+// sequence counter and gives its position in x. It returns io.EOF if there is
+// no such packet.
+func (f *File) SeekPacket(x *Index, pid int, seq uint) (int, error) {
+	return f.seekEntry(x, x.SearchPacket(pid, seq))
+}
+
+func (f *File) seekEntry(x *Index, i int) (int, error) {
+	if i < 0 {
+		return i, io.EOF
+	}
+	return i, f.seek(x.Entries[i].Offset)
+}
+
+// IndexOffsets gives the func expected by NewMerger and MergeFiles from the
+// indexes of the files to merge, given in the same order, so that headers of
+// the packets do not have to be decoded again. Packets that were not decoded
+// when their index was built are dropped.
+func IndexOffsets(xs ...*Index) func([]byte) (Offset, error) {
+	var es []IndexEntry
+	for _, x := range xs {
+		es = append(es, x.Entries...)
+	}
+	return func(bs []byte) (Offset, error) {
+		if len(es) == 0 || len(bs) != es[0].Length+4 {
+			return Offset{}, ErrStale
+		}
+		e := es[0]
+		es = es[1:]
+		if !e.Decoded {
+			return Offset{}, ErrSkip
+		}
+		o := Offset{
+			Pid:      uint(e.Pid),
+			Time:     e.When,
+			Sequence: e.Sequence,
+			Len:      uint(e.Length),
+		}
+		return o, nil
+	}
+}
+
+// MergeIndexed is like MergeFiles but takes the time and the identification of
+// the packets from the up to date indexes of the files. It fails when an index
+// was built without a HeaderDecoder or when none of the packets of the files
+// were decoded.
+func MergeIndexed(files []string, w io.Writer) error {
+	var (
+		xs      = make([]*Index, len(files))
+		decoded bool
+		empty   = true
+	)
+	for i, f := range files {
+		x, err := LoadIndex(f)
+		if err != nil {
+			return err
+		}
+		if !x.Headers {
+			return fmt.Errorf("%s: index built without decoder", f)
+		}
+		for _, e := range x.Entries {
+			empty, decoded = false, decoded || e.Decoded
+		}
+		xs[i] = x
+	}
+	if !empty && !decoded {
+		return fmt.Errorf("merge: no packet decoded in the indexes")
+	}
+	return MergeFiles(files, w, IndexOffsets(xs...))
+}
+
+// IndexOrder gives the time of the first packet of a file according to its
+// index. It can be given to OrderBy.
+func IndexOrder(file string) (time.Time, error) {
+	x, err := LoadIndex(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if i := x.SearchTime(time.Time{}); i >= 0 {
+		return x.Entries[i].When, nil
+	}
+	return time.Time{}, io.EOF
+}
+
+func (x *Index) current(i fs.FileInfo) bool {
+	return x.Size == i.Size() && x.ModTime.Equal(unixTime(unixNano(i.ModTime())))
+}
+
+// end gives the position following the last packet of the index.
+func (x *Index) end() int64 {
+	if len(x.Entries) == 0 {
+		return 0
+	}
+	e := x.Entries[len(x.Entries)-1]
+	return e.Offset + int64(e.Length) + 4
+}
+
+func (x *Index) scan(file string, from int64, d HeaderDecoder) error {
+	f, err := Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.seek(from); err != nil {
+		return err
+	}
+	s := NewScanner(f, WithResync(0))
+	for s.Scan() {
+		p := s.Packet()
+		e := IndexEntry{
+			Offset: p.Offset,
+			Length: len(p.Payload),
+		}
+		if d != nil {
+			if i, err := d.Decode(p.Payload); err == nil {
+				e.Pid, e.Sequence, e.When, e.Decoded = i.Pid, i.Sequence, i.When, true
+			}
+		}
+		x.Entries = append(x.Entries, e)
+	}
+	// a frame still being written is indexed on the next refresh
+	err = s.Err()
+	if _, ok := err.(TruncatedError); ok || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return err
+}
+
+func (x *Index) writeFile(file string) error {
+	w, err := ioutil.TempFile(filepath.Dir(file), filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := x.WriteTo(w); err != nil {
+		w.Close()
+		os.Remove(w.Name())
+		return err
+	}
+	if err := w.Close(); err != nil {
+		os.Remove(w.Name())
+		return err
+	}
+	return os.Rename(w.Name(), file)
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func unixTime(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
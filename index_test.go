@@ -0,0 +1,130 @@
+package rt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScannerStartAt(t *testing.T) {
+	var (
+		dir  = t.TempDir()
+		day  = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+		want = make(map[string]int64)
+	)
+	dec, err := LookupDecoder("generic:time=0/4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range []string{"a.dat", "b.dat"} {
+		p := filepath.Join(dir, n)
+		f, err := os.Create(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := NewWriter(f)
+		for j := 0; j < 3; j++ {
+			bs := make([]byte, 8)
+			binary.BigEndian.PutUint32(bs, uint32(day.Add(time.Duration(i+2*j)*time.Minute).Unix()))
+			w.Write(bs)
+		}
+		f.Close()
+		x, _, err := IndexFile(p, dec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range x.Entries {
+			want[e.When.Format("15:04")] = e.Offset
+		}
+	}
+	r, err := Browse([]string{dir}, false, StartAt(day.Add(2*time.Minute)), OrderBy(IndexOrder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var (
+		sc    = NewScanner(r)
+		count int
+	)
+	for sc.Scan() {
+		p := sc.Packet()
+		i, err := dec.Decode(p.Payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		when := i.When.Format("15:04")
+		if off, ok := want[when]; !ok || off != p.Offset {
+			t.Errorf("%s (%s): got offset %d, want %d", when, filepath.Base(p.File), p.Offset, off)
+		}
+		count++
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("got %d packets, want 4", count)
+	}
+}
+
+func TestIndexWithoutTime(t *testing.T) {
+	var (
+		dir   = t.TempDir()
+		file  = filepath.Join(dir, "a.dat")
+		plain = filepath.Join(dir, "b.dat")
+		size  int
+	)
+	for _, p := range []string{file, plain} {
+		f, err := os.Create(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := NewWriter(f)
+		for _, id := range [][2]uint16{{7, 2}, {7, 1}, {8, 1}} {
+			bs := make([]byte, 4)
+			binary.BigEndian.PutUint16(bs, id[0])
+			binary.BigEndian.PutUint16(bs[2:], id[1])
+			w.Write(bs)
+			size += 4 + len(bs)
+		}
+		f.Close()
+	}
+	size /= 2
+
+	dec, err := LookupDecoder("generic:pid=0/2,seq=2/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := IndexFile(file, dec); err != nil {
+		t.Fatal(err)
+	}
+	x, err := LoadIndex(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i := x.SearchPacket(7, 1); i != 1 {
+		t.Errorf("packet 7/1: got entry %d, want 1", i)
+	}
+	if i := x.SearchPacket(8, 1); i != 2 {
+		t.Errorf("packet 8/1: got entry %d, want 2", i)
+	}
+
+	var buf bytes.Buffer
+	if err := MergeIndexed([]string{file}, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != size {
+		t.Errorf("merged %d bytes, want %d", buf.Len(), size)
+	}
+
+	if _, _, err := IndexFile(plain, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := MergeIndexed([]string{plain}, ioutil.Discard); err == nil {
+		t.Errorf("merge with an index built without decoder should fail")
+	}
+}
@@ -0,0 +1,190 @@
+package rt
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultPattern is the pattern of the layout used by Path.
+const DefaultPattern = "%04Y/%04D/%04H/rt_%02M_%02+4M.dat"
+
+var DefaultLayout = mustLayout(DefaultPattern, Five)
+
+// Layout describes how the files of an archive are named. The pattern uses the
+// syntax of Parse and is relative to the base directory of the archive. The
+// time of a packet is truncated to the interval of the layout before being
+// formatted, so that all packets of an interval go to the same file.
+//
+// Scale is the time scale of the times of the packets (eg: ScaleGPS for times
+// decoded from HRDL headers). Files are named after the UTC time of their
+// packets; Info and Span give times in Scale.
+type Layout struct {
+	Pattern  string
+	Interval time.Duration
+	Scale    Scale
+
+	format Formatter
+}
+
+func ParseLayout(pattern string, interval time.Duration) (*Layout, error) {
+	f, err := Parse(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = Five
+	}
+	l := Layout{
+		Pattern:  pattern,
+		Interval: interval,
+		format:   f,
+	}
+	return &l, nil
+}
+
+func mustLayout(pattern string, interval time.Duration) *Layout {
+	l, err := ParseLayout(pattern, interval)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+// Path gives the file of the archive rooted at base where the given packet
+// should be written. The directories of the file are not created.
+func (l *Layout) Path(base string, i PacketInfo) string {
+	i.When = l.Scale.ToUTC(i.When).Truncate(l.Interval)
+	return filepath.Join(base, filepath.FromSlash(l.format.Format(i)))
+}
+
+// Info recovers the fields of a PacketInfo from the name of a file of the
+// archive rooted at base.
+func (l *Layout) Info(base, file string) (PacketInfo, error) {
+	rel, err := filepath.Rel(base, file)
+	if err != nil {
+		return PacketInfo{}, err
+	}
+	i, err := Extract(l.format, filepath.ToSlash(TrimCodec(rel)))
+	i.When = l.fromUTC(i.When)
+	return i, err
+}
+
+// Span gives the time interval covered by a file of the archive rooted at
+// base. The interval is at least as long as the interval of the layout.
+func (l *Layout) Span(base, file string) (time.Time, time.Time, error) {
+	rel, err := filepath.Rel(base, file)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	starts, ends, err := ExtractInterval(l.format, filepath.ToSlash(TrimCodec(rel)))
+	if err != nil || starts.IsZero() {
+		return starts, ends, err
+	}
+	if ends.Sub(starts) < l.Interval {
+		ends = starts.Add(l.Interval)
+	}
+	return l.fromUTC(starts), l.fromUTC(ends), nil
+}
+
+// fromUTC converts a time found in the name of a file to the scale of the
+// layout.
+func (l *Layout) fromUTC(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return l.Scale.FromUTC(t)
+}
+
+// Glob gives a pattern for filepath.Glob matching all the files of the archive
+// rooted at base.
+func (l *Layout) Glob(base string) string {
+	return filepath.Join(base, filepath.FromSlash(globPattern(l.format)))
+}
+
+// BrowseLayout is like Browse but only gives the files of the archive rooted
+// at base that match its layout, compressed or not.
+func BrowseLayout(base string, l *Layout, options ...BrowseOption) (io.ReadCloser, error) {
+	if l == nil {
+		l = DefaultLayout
+	}
+	cfg := newBrowseConfig(append([]BrowseOption{WithLayout(l)}, options...))
+	glob := func(pattern string) ([]string, error) {
+		return filepath.Glob(pattern)
+	}
+	pattern := l.Glob(base)
+	if cfg.fsys != nil {
+		glob = func(pattern string) ([]string, error) {
+			return fs.Glob(cfg.fsys, pattern)
+		}
+		pattern = path.Join(base, globPattern(l.format))
+	}
+	files, err := glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	others, err := glob(pattern + ".*")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range others {
+		if _, ok := decompressor(f); ok {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	r, err := openBrowser(cfg, func(ctx context.Context) <-chan entry {
+		q := make(chan entry)
+		go func() {
+			defer close(q)
+			for _, f := range files {
+				e := entry{file: f}
+				if i, err := cfg.stat(f); err != nil {
+					e.err = err
+				} else if i.IsDir() {
+					continue
+				}
+				if !send(ctx, q, e) {
+					return
+				}
+			}
+		}()
+		return q
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func globPattern(f Formatter) string {
+	switch f := f.(type) {
+	case literal:
+		return escapeGlob(string(f))
+	case formatter:
+		var str strings.Builder
+		for _, f := range f.funcs {
+			str.WriteString(globPattern(f))
+		}
+		return str.String()
+	default:
+		return "*"
+	}
+}
+
+func escapeGlob(str string) string {
+	var b strings.Builder
+	for _, c := range str {
+		switch c {
+		case '*', '?', '[', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
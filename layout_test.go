@@ -0,0 +1,99 @@
+package rt
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLayoutScale(t *testing.T) {
+	data := []struct {
+		Scale Scale
+		When  time.Time
+		File  string
+	}{
+		{
+			Scale: ScaleUTC,
+			When:  time.Date(2017, 1, 1, 0, 0, 10, 0, time.UTC),
+			File:  "2017/0001/0000/rt_00_04.dat",
+		},
+		{
+			Scale: ScaleGPS,
+			When:  time.Date(2017, 1, 1, 0, 0, 10, 0, time.UTC),
+			File:  "2016/0366/0023/rt_55_59.dat",
+		},
+		{
+			Scale: ScaleGPS,
+			When:  time.Date(2017, 1, 1, 0, 0, 20, 0, time.UTC),
+			File:  "2017/0001/0000/rt_00_04.dat",
+		},
+		{
+			Scale: ScaleTAI,
+			When:  time.Date(2017, 1, 1, 0, 0, 40, 0, time.UTC),
+			File:  "2017/0001/0000/rt_00_04.dat",
+		},
+		{
+			Scale: ScaleTAI,
+			When:  time.Date(2017, 1, 1, 0, 0, 35, 0, time.UTC),
+			File:  "2016/0366/0023/rt_55_59.dat",
+		},
+	}
+	for _, d := range data {
+		l := *DefaultLayout
+		l.Scale = d.Scale
+
+		file := l.Path("arch", PacketInfo{When: d.When})
+		if want := filepath.Join("arch", filepath.FromSlash(d.File)); file != want {
+			t.Errorf("%s (%s): expected %s, got %s", d.When, d.Scale, want, file)
+			continue
+		}
+		starts, ends, err := l.Span("arch", file)
+		if err != nil {
+			t.Errorf("%s: %v", file, err)
+			continue
+		}
+		if d.When.Before(starts) || !d.When.Before(ends) {
+			t.Errorf("%s (%s): %s not in [%s, %s)", file, d.Scale, d.When, starts, ends)
+		}
+	}
+}
+
+func TestLayoutExtract(t *testing.T) {
+	var (
+		when = time.Date(2019, 3, 7, 13, 25, 0, 0, time.UTC)
+		info = PacketInfo{
+			UPI:  "MMA_IMAGE",
+			Pid:  51,
+			Sid:  7,
+			When: when,
+		}
+	)
+	data := []struct {
+		Pattern string
+		Want    PacketInfo
+	}{
+		{Pattern: "%T.dat", Want: PacketInfo{When: when}},
+		{Pattern: "%04Y/%03D/%02H/rt_%02M.dat", Want: PacketInfo{When: when}},
+		{Pattern: "%Y-%02m-%02d/%02H%02M.dat", Want: PacketInfo{When: when}},
+		{Pattern: "%Y/%D/%H/rt_%M_%+4M.dat", Want: PacketInfo{When: when}},
+		{Pattern: "%P/%S/%T.dat", Want: PacketInfo{Pid: 51, Sid: 7, When: when}},
+		{Pattern: "%U/%04Y%03D.dat", Want: PacketInfo{UPI: "MMA_IMAGE", When: when.Truncate(24 * time.Hour)}},
+		{Pattern: "%P_%S.dat", Want: PacketInfo{Pid: 51, Sid: 7}},
+	}
+	for _, d := range data {
+		l, err := ParseLayout(d.Pattern, time.Minute)
+		if err != nil {
+			t.Errorf("%s: %v", d.Pattern, err)
+			continue
+		}
+		file := l.Path("arch", info)
+		got, err := l.Info("arch", file)
+		if err != nil {
+			t.Errorf("%s: %s: %v", d.Pattern, file, err)
+			continue
+		}
+		if got.UPI != d.Want.UPI || got.Pid != d.Want.Pid || got.Sid != d.Want.Sid || !got.When.Equal(d.Want.When) {
+			t.Errorf("%s: %s: expected %+v, got %+v", d.Pattern, file, d.Want, got)
+		}
+	}
+}
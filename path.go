@@ -8,10 +8,12 @@ import (
 )
 
 type PacketInfo struct {
-	UPI  string
-	Pid  int
-	Sid  int
-	When time.Time
+	UPI      string
+	Pid      int
+	Sid      int
+	Sequence uint
+	Segment  Segment
+	When     time.Time
 }
 
 type Formatter interface {
@@ -83,10 +85,11 @@ const (
 	pad3 = 3
 )
 
-// syntax: %[0][+/-0]X
+// syntax: %[0[width]][+/-0]X
 type specifier struct {
-	add time.Duration
-	sub time.Duration
+	kind byte
+	add  time.Duration
+	sub  time.Duration
 
 	padding   int
 	transform formatFunc
@@ -97,25 +100,52 @@ func parseSpecifier(pattern string) (Formatter, int, error) {
 		offset  int
 		add     int
 		sub     int
+		width   int
 		padding bool
 		spec    specifier
 	)
-	if pattern[offset] == '0' {
+	if offset < len(pattern) && pattern[offset] == '0' {
 		offset++
 		padding = true
+		pos := offset
+		for offset < len(pattern) && isDigit(pattern[offset]) {
+			offset++
+		}
+		if pos < offset {
+			n, err := strconv.Atoi(pattern[pos:offset])
+			if err != nil {
+				return nil, 0, err
+			}
+			width = n
+		}
+	}
+	pad := func(n int) int {
+		if !padding {
+			return pad0
+		}
+		if width > 0 {
+			return width
+		}
+		return n
 	}
 
 	for {
+		if offset >= len(pattern) {
+			return nil, 0, fmt.Errorf("invalid syntax: missing specifier")
+		}
 		if isLetter(pattern[offset]) {
 			break
 		}
 		if char := pattern[offset]; char == '-' || char == '+' {
 			offset++
+			if offset >= len(pattern) {
+				return nil, 0, fmt.Errorf("invalid syntax: missing specifier")
+			}
 			if !isDigit(pattern[offset]) {
 				return nil, 0, fmt.Errorf("invalid syntax: unexpected character %c (should be a digit)", pattern[offset])
 			}
 			pos := offset
-			for isDigit(pattern[offset]) {
+			for offset < len(pattern) && isDigit(pattern[offset]) {
 				offset++
 			}
 			n, err := strconv.Atoi(pattern[pos:offset])
@@ -132,41 +162,33 @@ func parseSpecifier(pattern string) (Formatter, int, error) {
 		}
 	}
 
+	spec.kind = pattern[offset]
 	switch pattern[offset] {
 	case 'T':
 		spec.sub = time.Duration(sub) * time.Second
 		spec.add = time.Duration(add) * time.Second
 		spec.transform = spec.formatTimestamp
 	case 'Y':
+		spec.padding = pad(pad0)
 		spec.transform = spec.formatYear
 	case 'D':
-		if padding {
-			spec.padding = pad3
-		}
+		spec.padding = pad(pad3)
 		spec.sub = time.Duration(sub) * time.Hour * 24
 		spec.add = time.Duration(add) * time.Hour * 24
 		spec.transform = spec.formatDOY
 	case 'm':
-		if padding {
-			spec.padding = pad2
-		}
+		spec.padding = pad(pad2)
 		spec.transform = spec.formatMonth
 	case 'd':
-		if padding {
-			spec.padding = pad2
-		}
+		spec.padding = pad(pad2)
 		spec.transform = spec.formatDay
 	case 'H':
-		if padding {
-			spec.padding = pad2
-		}
+		spec.padding = pad(pad2)
 		spec.sub = time.Duration(sub) * time.Hour
 		spec.add = time.Duration(add) * time.Hour
 		spec.transform = spec.formatHour
 	case 'M':
-		if padding {
-			spec.padding = pad2
-		}
+		spec.padding = pad(pad2)
 		spec.sub = time.Duration(sub) * time.Minute
 		spec.add = time.Duration(add) * time.Minute
 		spec.transform = spec.formatMinute
@@ -193,7 +215,7 @@ func (s specifier) formatTimestamp(i PacketInfo) string {
 }
 
 func (s specifier) formatYear(i PacketInfo) string {
-	return formatInt(int64(i.When.Year()), pad0)
+	return formatInt(int64(i.When.Year()), s.padding)
 }
 
 func (s specifier) formatDOY(i PacketInfo) string {
@@ -231,6 +253,182 @@ func (s specifier) formatUPI(i PacketInfo) string {
 	return i.UPI
 }
 
+// Extract is the reverse of Format: it recovers the fields of a PacketInfo
+// from a string formatted with f. Fields that are computed with an offset
+// (+N) are checked to be numbers but are otherwise ignored.
+func Extract(f Formatter, str string) (PacketInfo, error) {
+	i, _, err := extract(f, str)
+	return i, err
+}
+
+// ExtractInterval gives the time interval covered by a string formatted with
+// f, taking into account the finest time field used by f and its truncation.
+func ExtractInterval(f Formatter, str string) (time.Time, time.Time, error) {
+	i, end, err := extract(f, str)
+	return i.When, end, err
+}
+
+func extract(f Formatter, str string) (PacketInfo, time.Time, error) {
+	var (
+		info   PacketInfo
+		funcs  []Formatter
+		fields = make(map[byte]int64)
+		unit   byte
+		res    time.Duration
+	)
+	switch f := f.(type) {
+	case formatter:
+		funcs = f.funcs
+	default:
+		funcs = []Formatter{f}
+	}
+	for j, fn := range funcs {
+		switch fn := fn.(type) {
+		case literal:
+			if !strings.HasPrefix(str, string(fn)) {
+				return info, time.Time{}, fmt.Errorf("extract: %q does not match %q", str, fn)
+			}
+			str = str[len(fn):]
+		case specifier:
+			var next Formatter
+			for _, n := range funcs[j+1:] {
+				if lit, ok := n.(literal); ok && lit == "" {
+					continue
+				}
+				next = n
+				break
+			}
+			var value string
+			if fn.kind == 'U' {
+				n := len(str)
+				if lit, ok := next.(literal); ok {
+					// an UPI can contain the separator that follows it: look
+					// for its last occurrence in the current path element.
+					seg := str
+					if ix := strings.IndexByte(str, '/'); ix >= 0 && !strings.Contains(string(lit), "/") {
+						seg = str[:ix]
+					}
+					if ix := strings.LastIndex(seg, string(lit)); ix >= 0 {
+						n = ix
+					} else if ix := strings.Index(str, string(lit)); ix >= 0 {
+						n = ix
+					}
+				}
+				value, str = str[:n], str[n:]
+				info.UPI = value
+				continue
+			}
+			width := fn.padding
+			if width == 0 && fn.kind == 'Y' {
+				width = 4
+			}
+			n := 0
+			for n < len(str) && isDigit(str[n]) {
+				if _, ok := next.(specifier); ok && width > 0 && n == width {
+					break
+				}
+				n++
+			}
+			if n == 0 {
+				return info, time.Time{}, fmt.Errorf("extract: expected number for %%%c in %q", fn.kind, str)
+			}
+			value, str = str[:n], str[n:]
+			v, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return info, time.Time{}, err
+			}
+			if fn.add > 0 {
+				continue
+			}
+			switch fn.kind {
+			case 'P':
+				info.Pid = int(v)
+			case 'S':
+				info.Sid = int(v)
+			default:
+				fields[fn.kind] = v
+				if timeRank(fn.kind) >= timeRank(unit) {
+					unit = fn.kind
+					if fn.sub > res {
+						res = fn.sub
+					}
+				}
+			}
+		}
+	}
+	if str != "" {
+		return info, time.Time{}, fmt.Errorf("extract: unexpected %q", str)
+	}
+	if len(fields) == 0 {
+		return info, time.Time{}, nil
+	}
+	if v, ok := fields['T']; ok {
+		info.When = time.Unix(v, 0).UTC()
+	} else {
+		var (
+			year   = int(fields['Y'])
+			month  = time.January
+			day    = 1
+			hour   = int(fields['H'])
+			minute = int(fields['M'])
+		)
+		if v, ok := fields['m']; ok {
+			month = time.Month(v)
+		}
+		if v, ok := fields['d']; ok {
+			day = int(v)
+		}
+		info.When = time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+		if v, ok := fields['D']; ok {
+			info.When = info.When.AddDate(0, 0, int(v)-1)
+		}
+	}
+
+	var end time.Time
+	switch unit {
+	case 'Y':
+		end = info.When.AddDate(1, 0, 0)
+	case 'm':
+		end = info.When.AddDate(0, 1, 0)
+	default:
+		var d time.Duration
+		switch unit {
+		case 'D', 'd':
+			d = time.Hour * 24
+		case 'H':
+			d = time.Hour
+		case 'M':
+			d = time.Minute
+		case 'T':
+			d = time.Second
+		}
+		if res > d {
+			d = res
+		}
+		end = info.When.Add(d)
+	}
+	return info, end, nil
+}
+
+func timeRank(b byte) int {
+	switch b {
+	case 'Y':
+		return 1
+	case 'm':
+		return 2
+	case 'D', 'd':
+		return 3
+	case 'H':
+		return 4
+	case 'M':
+		return 5
+	case 'T':
+		return 6
+	default:
+		return 0
+	}
+}
+
 func formatInt(n int64, padding int) string {
 	if padding < 0 {
 		padding = 0
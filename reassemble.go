@@ -0,0 +1,239 @@
+package rt
+
+import (
+	"time"
+)
+
+type Segment uint8
+
+const (
+	Unsegmented Segment = iota
+	First
+	Continuation
+	Last
+)
+
+func (s Segment) String() string {
+	switch s {
+	case First:
+		return "first"
+	case Continuation:
+		return "continuation"
+	case Last:
+		return "last"
+	default:
+		return "unsegmented"
+	}
+}
+
+type Product struct {
+	Pid      int
+	Sid      int
+	First    uint
+	Last     uint
+	Segments int
+	Starts   time.Time
+	Ends     time.Time
+	Payload  []byte
+}
+
+type segment struct {
+	PacketInfo
+	payload []byte
+	arrived time.Time
+}
+
+type assemblyKey struct {
+	Pid int
+	Sid int
+}
+
+// Reassembler groups the segments of products split across several packets.
+// Segments are grouped by pid and sid and chained by their sequence counter,
+// so they can arrive in any order. Segments waiting for longer than the
+// timeout (measured with the time of the packets) are dropped and their
+// product is counted as incomplete.
+//
+// The first segment of a product is kept as is; the first strip bytes of the
+// following segments (usually their header) are removed before being
+// appended to the product.
+type Reassembler struct {
+	decoder HeaderDecoder
+	timeout time.Duration
+	strip   int
+	modulo  uint
+
+	pending map[assemblyKey]map[uint]segment
+	ready   []Product
+	stats   Coze
+}
+
+func NewReassembler(d HeaderDecoder, timeout time.Duration, strip int) *Reassembler {
+	return &Reassembler{
+		decoder: d,
+		timeout: timeout,
+		strip:   strip,
+		modulo:  1 << 14,
+		pending: make(map[assemblyKey]map[uint]segment),
+	}
+}
+
+// SetCounter gives the width in bits of the sequence counter of the packets
+// (14 bits by default).
+func (r *Reassembler) SetCounter(bits int) {
+	if bits <= 0 || bits > 32 {
+		return
+	}
+	r.modulo = 1 << uint(bits)
+}
+
+// Push decodes the given payload and adds it to the product it belongs to.
+func (r *Reassembler) Push(bs []byte) error {
+	i, err := r.decoder.Decode(bs)
+	if err != nil {
+		r.stats.Error++
+		return err
+	}
+	now := i.When
+	if now.IsZero() {
+		now = time.Now()
+	}
+	r.expire(now.Add(-r.timeout))
+
+	g := segment{
+		PacketInfo: i,
+		payload:    append([]byte(nil), bs...),
+		arrived:    now,
+	}
+	if i.Segment == Unsegmented {
+		r.emit([]segment{g})
+		return nil
+	}
+
+	k := assemblyKey{Pid: i.Pid, Sid: i.Sid}
+	set, ok := r.pending[k]
+	if !ok {
+		set = make(map[uint]segment)
+		r.pending[k] = set
+	}
+	seq := i.Sequence % r.modulo
+	if _, ok := set[seq]; ok {
+		r.stats.Error++
+		return nil
+	}
+	set[seq] = g
+	r.assemble(set)
+	if len(set) == 0 {
+		delete(r.pending, k)
+	}
+	return nil
+}
+
+// Next gives the next complete product.
+func (r *Reassembler) Next() (Product, bool) {
+	if len(r.ready) == 0 {
+		return Product{}, false
+	}
+	p := r.ready[0]
+	r.ready = r.ready[1:]
+	return p, true
+}
+
+// Flush drops all the pending segments and counts their products as
+// incomplete.
+func (r *Reassembler) Flush() {
+	for k, set := range r.pending {
+		r.drop(set, func(segment) bool { return true })
+		delete(r.pending, k)
+	}
+}
+
+// Stats gives the number of complete products (Count) and their size, the
+// number of incomplete products (Missing) and the number of invalid or
+// duplicated segments (Error).
+func (r *Reassembler) Stats() Coze {
+	return r.stats
+}
+
+func (r *Reassembler) assemble(set map[uint]segment) {
+	for seq, g := range set {
+		if g.Segment != First {
+			continue
+		}
+		chain := []segment{g}
+		for n := (seq + 1) % r.modulo; ; n = (n + 1) % r.modulo {
+			c, ok := set[n]
+			if !ok || c.Segment == First {
+				break
+			}
+			chain = append(chain, c)
+			if c.Segment == Last {
+				for _, c := range chain {
+					delete(set, c.Sequence%r.modulo)
+				}
+				r.emit(chain)
+				break
+			}
+		}
+	}
+}
+
+func (r *Reassembler) expire(limit time.Time) {
+	if r.timeout <= 0 {
+		return
+	}
+	for k, set := range r.pending {
+		r.drop(set, func(g segment) bool { return g.arrived.Before(limit) })
+		if len(set) == 0 {
+			delete(r.pending, k)
+		}
+	}
+}
+
+func (r *Reassembler) drop(set map[uint]segment, fn func(segment) bool) {
+	var firsts, orphans int
+	for seq, g := range set {
+		if !fn(g) {
+			continue
+		}
+		if g.Segment == First {
+			firsts++
+		} else {
+			orphans++
+		}
+		delete(set, seq)
+	}
+	r.stats.Missing += uint64(firsts)
+	if firsts == 0 && orphans > 0 {
+		r.stats.Missing++
+	}
+}
+
+func (r *Reassembler) emit(chain []segment) {
+	var (
+		first = chain[0]
+		last  = chain[len(chain)-1]
+		p     = Product{
+			Pid:      first.Pid,
+			Sid:      first.Sid,
+			First:    first.Sequence,
+			Last:     last.Sequence,
+			Segments: len(chain),
+			Starts:   first.When,
+			Ends:     last.When,
+			Payload:  first.payload,
+		}
+	)
+	for _, c := range chain[1:] {
+		bs := c.payload
+		if r.strip < len(bs) {
+			bs = bs[r.strip:]
+		} else {
+			bs = nil
+		}
+		p.Payload = append(p.Payload, bs...)
+	}
+	r.stats.Count++
+	r.stats.Size += uint64(len(p.Payload))
+	r.ready = append(r.ready, p)
+}
@@ -0,0 +1,84 @@
+package rt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestReassembleHRDL(t *testing.T) {
+	when := time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC)
+	vmu := func(seq uint32, seg Segment, data string) []byte {
+		bs := make([]byte, vmuHeaderLen, vmuHeaderLen+len(data))
+		copy(bs, "\xf8\x2e\x35\x53")
+		binary.LittleEndian.PutUint32(bs[4:], uint32(vmuHeaderLen-8+len(data)))
+		bs[8], bs[9] = 2, 51
+		binary.LittleEndian.PutUint32(bs[12:], seq)
+		binary.LittleEndian.PutUint32(bs[16:], uint32(when.Sub(GPS)/time.Second)+seq)
+		bs[vmuFlagsOffset] = byte(seg) << 6
+		return append(bs, data...)
+	}
+	var (
+		buf bytes.Buffer
+		w   = NewWriter(&buf)
+	)
+	for _, bs := range [][]byte{
+		vmu(10, Unsegmented, "whole"),
+		vmu(12, Continuation, "-two"),
+		vmu(11, First, "one"),
+		vmu(14, Last, "-four"),
+		vmu(13, Continuation, "-three"),
+		vmu(20, Continuation, "orphan"),
+	} {
+		if _, err := w.Write(bs); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err := LookupDecoder("hrdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		r  = NewReassembler(d, time.Minute, vmuHeaderLen)
+		sc = NewScanner(&buf)
+	)
+	for sc.Scan() {
+		if err := r.Push(sc.Packet().Payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatal(err)
+	}
+	r.Flush()
+
+	want := []struct {
+		First    uint
+		Last     uint
+		Segments int
+		Data     string
+	}{
+		{First: 10, Last: 10, Segments: 1, Data: "whole"},
+		{First: 11, Last: 14, Segments: 4, Data: "one-two-three-four"},
+	}
+	for _, w := range want {
+		p, ok := r.Next()
+		if !ok {
+			t.Fatalf("product %d-%d: not assembled", w.First, w.Last)
+		}
+		if p.Pid != 2 || p.First != w.First || p.Last != w.Last || p.Segments != w.Segments {
+			t.Errorf("product %d-%d: got pid %d, %d-%d (%d segments)", w.First, w.Last, p.Pid, p.First, p.Last, p.Segments)
+		}
+		if data := string(p.Payload[vmuHeaderLen:]); data != w.Data {
+			t.Errorf("product %d-%d: got %q, want %q", w.First, w.Last, data, w.Data)
+		}
+	}
+	if p, ok := r.Next(); ok {
+		t.Errorf("unexpected product %d-%d", p.First, p.Last)
+	}
+	if c := r.Stats(); c.Count != 2 || c.Missing != 1 {
+		t.Errorf("got %d products and %d incomplete, want 2 and 1", c.Count, c.Missing)
+	}
+}
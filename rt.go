@@ -1,12 +1,15 @@
 package rt
 
 import (
-	// "bufio"
+	"bufio"
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
+	"math"
 	"math/rand"
 	"os"
 	"path/filepath"
@@ -32,16 +35,19 @@ func (e TruncatedError) Error() string {
 }
 
 type Coze struct {
-	First     uint64
-	Last      uint64
-	StartTime time.Time
-	EndTime   time.Time
+	First     uint64    `json:"first"`
+	Last      uint64    `json:"last"`
+	StartTime time.Time `json:"dtstart"`
+	EndTime   time.Time `json:"dtend"`
 
-	Id      int    `json:"id"`
-	Size    uint64 `json:"bytes"`
-	Count   uint64 `json:"count"`
-	Missing uint64 `json:"missing"`
-	Error   uint64 `json:"error"`
+	Id        int    `json:"id"`
+	Size      uint64 `json:"bytes"`
+	Count     uint64 `json:"count"`
+	Missing   uint64 `json:"missing"`
+	Error     uint64 `json:"error"`
+	Duplicate uint64 `json:"duplicate"`
+	Reorder   uint64 `json:"reorder"`
+	Restart   uint64 `json:"restart"`
 }
 
 func (c *Coze) Reset() {
@@ -49,6 +55,9 @@ func (c *Coze) Reset() {
 	c.Count = 0
 	c.Error = 0
 	c.Missing = 0
+	c.Duplicate = 0
+	c.Reorder = 0
+	c.Restart = 0
 	c.First = 0
 	c.Last = 0
 	c.StartTime = time.Time{}
@@ -60,6 +69,9 @@ func (c *Coze) Update(o *Coze) {
 	c.Count += o.Count
 	c.Error += o.Error
 	c.Missing += o.Missing
+	c.Duplicate += o.Duplicate
+	c.Reorder += o.Reorder
+	c.Restart += o.Restart
 }
 
 type Gap struct {
@@ -68,13 +80,32 @@ type Gap struct {
 	Ends   time.Time `json:"dtend"`
 	Last   int       `json:"last"`
 	First  int       `json:"first"`
+	Bits   int       `json:"bits,omitempty"`
+
+	// Estimated is set for the gaps found from the time of the packets only.
+	Estimated int `json:"estimated,omitempty"`
+
+	// bucket is the interval where an Analyzer counted the missing packets
+	// (see SetBucket).
+	bucket int64
 }
 
 func (g *Gap) Duration() time.Duration {
 	return g.Ends.Sub(g.Starts)
 }
 
+// Missing gives the number of packets lost between Last and First. When Bits
+// gives the width of the sequence counter, the counter can wrap between them.
+// For gaps found from the time of the packets, it gives the estimated number
+// of missing packets.
 func (g *Gap) Missing() int {
+	if g.Estimated > 0 {
+		return g.Estimated
+	}
+	if g.Bits > 0 && g.Bits < 64 {
+		m := uint64(1) << uint(g.Bits)
+		return int((uint64(g.First)-uint64(g.Last))%m) - 1
+	}
 	d := g.First - g.Last
 	if d < 0 {
 		d = -d
@@ -116,9 +147,14 @@ type Merger struct {
 }
 
 func MergeFiles(files []string, w io.Writer, f func([]byte) (Offset, error)) error {
+	return MergeFS(nil, files, w, f)
+}
+
+// MergeFS is like MergeFiles but reads the files from fsys.
+func MergeFS(fsys fs.FS, files []string, w io.Writer, f func([]byte) (Offset, error)) error {
 	rs := make([]io.Reader, len(files))
 	for i := 0; i < len(rs); i++ {
-		r, err := os.Open(files[i])
+		r, err := OpenFS(fsys, files[i])
 		if err != nil {
 			return err
 		}
@@ -207,32 +243,101 @@ func (m *Merger) Close() error {
 }
 
 func Path(base string, t time.Time) (string, error) {
-	t = t.Truncate(Five)
+	p := DefaultLayout.Path(base, PacketInfo{When: t})
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return "", err
+	}
+	return p, nil
+}
 
-	year := fmt.Sprintf("%04d", t.Year())
-	doy := fmt.Sprintf("%04d", t.YearDay())
-	hour := fmt.Sprintf("%04d", t.Hour())
+type SkipError int
 
-	dir := filepath.Join(base, year, doy, hour)
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return "", err
+func (e SkipError) Error() string {
+	return fmt.Sprintf("rt: skipped %d bytes", int(e))
+}
+
+const syncBufferSize = 1 << 20
+
+type ReaderOption func(*Reader)
+
+// WithResync makes the Reader scan forward, byte by byte, for the next
+// plausible frame instead of failing on a corrupted length prefix. A length
+// is sane when it is not zero and not greater than limit (or the size of the
+// buffer given to Read if limit is 0). Each frame should also be followed by
+// another sane length prefix or by the end of the stream and pass the check
+// set with WithCheck; otherwise its length prefix is considered corrupted and
+// the Reader scans forward from the next byte. The frame preceding garbage is
+// then discarded with the garbage.
+//
+// A frame that is not complete at the end of the stream is given, with a
+// TruncatedError, when it directly follows the previous frame. After a
+// resynchronization, it is discarded.
+func WithResync(limit int) ReaderOption {
+	return func(r *Reader) {
+		r.resync = true
+		r.limit = limit
+	}
+}
+
+// WithCheck registers an additional test that the payload of each frame
+// should pass when the Reader is in resync mode (see WithResync).
+func WithCheck(fn MatchFunc) ReaderOption {
+	return func(r *Reader) {
+		r.check = fn
+	}
+}
+
+// WithMatch installs a filter on the Reader. Frames whose payload does not
+// match are silently dropped.
+func WithMatch(fn MatchFunc) ReaderOption {
+	return func(r *Reader) {
+		r.SetMatch(fn)
+	}
+}
+
+// WithWindow limits the number of bytes of the payload given to the filter
+// of the Reader. A value of 0 gives the full payload.
+func WithWindow(n int) ReaderOption {
+	return func(r *Reader) {
+		r.window = n
+	}
+}
+
+// OnSkip registers a func called with the number of bytes discarded each
+// time the Reader resynchronizes.
+func OnSkip(fn func(int)) ReaderOption {
+	return func(r *Reader) {
+		r.skip = fn
 	}
-	min := t.Minute()
-	file := fmt.Sprintf("rt_%02d_%02d.dat", min, min+4)
-	return filepath.Join(dir, file), nil
 }
 
 type Reader struct {
-	// inner *bufio.Reader
 	inner io.Reader
+	buf   *bufio.Reader
 
 	match  MatchFunc
+	window int
 	needed int
+
+	resync  bool
+	limit   int
+	check   MatchFunc
+	skip    func(int)
+	skipped int64
+
+	// set by Scanner: a too small buffer is reported with io.ErrShortBuffer
+	// and the pending frame is kept for the next call to Read.
+	grow    bool
+	pending bool
+	pos     int64
 }
 
-func NewReader(r io.Reader) *Reader {
+func NewReader(r io.Reader, options ...ReaderOption) *Reader {
 	var rs Reader
-	rs.match = func([]byte) bool { return true }
+	rs.SetMatch(nil)
+	for _, o := range options {
+		o(&rs)
+	}
 	rs.Reset(r)
 	return &rs
 }
@@ -241,29 +346,79 @@ func (r *Reader) Reset(rs io.Reader) {
 	if rs == nil {
 		return
 	}
-	// if r.inner == nil {
-	// 	r.inner = bufio.NewReader(rs)
-	// } else {
-	// 	r.inner.Reset(rs)
-	// }
+	if r.resync {
+		if r.buf == nil {
+			r.buf = bufio.NewReaderSize(rs, syncBufferSize)
+		} else {
+			r.buf.Reset(rs)
+		}
+	}
 	r.inner = rs
 	r.needed = 0
+	r.skipped = 0
+	r.pending = false
+	r.pos = 0
+}
+
+func (r *Reader) SetMatch(fn MatchFunc) {
+	if fn == nil {
+		fn = func([]byte) bool { return true }
+	}
+	r.match = fn
+}
+
+// Skipped gives the number of bytes discarded while resynchronizing since
+// the last call to Reset.
+func (r *Reader) Skipped() int64 {
+	return r.skipped
 }
 
 func (r *Reader) Read(xs []byte) (int, error) {
 	if r.inner == nil {
 		return 0, nil
 	}
+	for {
+		var (
+			n   int
+			err error
+		)
+		if r.resync {
+			n, err = r.readSync(xs)
+		} else {
+			n, err = r.readFrame(xs)
+		}
+		if err != nil || r.accept(xs[4:n]) {
+			return n, err
+		}
+	}
+}
 
-	if _, err := r.inner.Read(xs[:4]); err != nil {
-		return 0, err
+func (r *Reader) accept(xs []byte) bool {
+	if r.window > 0 && len(xs) > r.window {
+		xs = xs[:r.window]
 	}
+	return r.match(xs)
+}
 
-	r.needed = int(binary.LittleEndian.Uint32(xs)) + 4
+func (r *Reader) readFrame(xs []byte) (int, error) {
+	if r.pending {
+		r.pending = false
+		binary.LittleEndian.PutUint32(xs, uint32(r.needed-4))
+	} else {
+		if _, err := io.ReadFull(r.inner, xs[:4]); err != nil {
+			return 0, err
+		}
+		r.pos += 4
+		r.needed = int(binary.LittleEndian.Uint32(xs)) + 4
+	}
 	if len(xs) < r.needed {
+		if r.grow && (r.limit <= 0 || r.needed-4 <= r.limit) {
+			r.pending = true
+			return 0, io.ErrShortBuffer
+		}
 		if d, ok := r.inner.(*multiReader); ok {
 			if err := d.closeAndOpen(); err == nil {
-				return r.Read(xs)
+				return r.readFrame(xs)
 			} else {
 				return 0, ErrInvalid
 			}
@@ -273,21 +428,143 @@ func (r *Reader) Read(xs []byte) (int, error) {
 	}
 
 	n, err := io.ReadFull(r.inner, xs[4:r.needed])
-	if !r.match(xs[4 : 4+256]) {
-		return r.Read(xs)
+	r.pos += int64(n)
+	return n + 4, err
+}
+
+func (r *Reader) readSync(xs []byte) (int, error) {
+	if len(xs) < 4 {
+		return 0, io.ErrShortBuffer
+	}
+	var skipped int
+	avail := len(xs)
+	if r.grow {
+		avail = math.MaxInt32
+	}
+	for {
+		ok, err := r.plausible(avail, skipped == 0)
+		if ok {
+			break
+		}
+		if err != nil {
+			n, _ := r.buf.Discard(r.buf.Buffered())
+			r.discard(skipped + n)
+			return 0, err
+		}
+		r.buf.Discard(1)
+		skipped++
+	}
+	r.discard(skipped)
+	if len(xs) < r.needed {
+		return 0, io.ErrShortBuffer
 	}
 
-	return n + 4, err
+	n, err := io.ReadFull(r.buf, xs[:r.needed])
+	r.pos += int64(n)
+	if err == io.ErrUnexpectedEOF {
+		return n, TruncatedError(r.needed - n)
+	}
+	return n, err
+}
+
+// plausible reports whether the next bytes of the stream look like a frame.
+// When synced is false, bytes were discarded just before the candidate frame.
+func (r *Reader) plausible(avail int, synced bool) (bool, error) {
+	xs, err := r.buf.Peek(4)
+	if err != nil {
+		return false, err
+	}
+	size := int(binary.LittleEndian.Uint32(xs))
+	if !r.sane(size, avail) {
+		return false, nil
+	}
+	r.needed = size + 4
+
+	xs, err = r.buf.Peek(r.needed + 4)
+	switch {
+	case len(xs) < r.needed && err != bufio.ErrBufferFull:
+		return synced, nil
+	case len(xs) == r.needed+4:
+		if !r.sane(int(binary.LittleEndian.Uint32(xs[r.needed:])), avail) {
+			return false, nil
+		}
+	}
+	if r.check != nil {
+		if len(xs) > r.needed {
+			xs = xs[:r.needed]
+		}
+		return r.check(xs[4:]), nil
+	}
+	return true, nil
+}
+
+func (r *Reader) sane(size, avail int) bool {
+	if size == 0 || size+4 > avail {
+		return false
+	}
+	return r.limit <= 0 || size <= r.limit
+}
+
+func (r *Reader) discard(n int) {
+	if n == 0 {
+		return
+	}
+	r.skipped += int64(n)
+	r.pos += int64(n)
+	if r.skip != nil {
+		r.skip(n)
+	}
 }
 
 type multiReader struct {
-	inner *os.File
-	files <-chan string
+	inner *File
+	next  func() (*File, error)
+	skip  func(string, error)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	bundle io.Closer
+}
+
+// Browse gives a stream made of the .dat files found in the given files and
+// directories. Unless Unordered is given, files are sorted by the time encoded
+// in their path according to the layout of the archive (see WithLayout),
+// whatever the root they come from. With WithFS, files are looked for in the
+// given filesystem instead of the OS one.
+func Browse(files []string, recurse bool, options ...BrowseOption) (io.ReadCloser, error) {
+	cfg := newBrowseConfig(options)
+	r, err := openBrowser(cfg, func(ctx context.Context) <-chan entry {
+		return walk(ctx, files, recurse, cfg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// BrowseContext is like Browse but stops walking the given files when ctx is
+// cancelled.
+func BrowseContext(ctx context.Context, files []string, recurse bool, options ...BrowseOption) (io.ReadCloser, error) {
+	return Browse(files, recurse, append(options, WithContext(ctx))...)
+}
+
+func openBrowser(cfg browseConfig, walker func(context.Context) <-chan entry) (*multiReader, error) {
+	ctx, cancel := context.WithCancel(cfg.ctx)
+	files := walker(ctx)
+	return newMultiReader(ctx, cancel, func() (*File, error) {
+		return openEntry(ctx, files, cfg)
+	}, cfg.skip)
 }
 
-func Browse(files []string, recurse bool) (io.ReadCloser, error) {
-	r := multiReader{files: walk(files, recurse)}
-	if f, err := r.openFile(); err != nil {
+func newMultiReader(ctx context.Context, cancel context.CancelFunc, next func() (*File, error), skip func(string, error)) (*multiReader, error) {
+	r := multiReader{
+		next:   next,
+		skip:   skip,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if f, err := r.next(); err != nil {
+		cancel()
 		return nil, err
 	} else {
 		r.inner = f
@@ -298,64 +575,187 @@ func Browse(files []string, recurse bool) (io.ReadCloser, error) {
 func (m *multiReader) closeAndOpen() error {
 	m.inner.Close()
 
-	f, err := m.openFile()
+	f, err := m.next()
 	if err == nil {
 		m.inner = f
+	} else {
+		m.inner = nil
 	}
 	return err
 }
 
 func (m *multiReader) Read(xs []byte) (int, error) {
+	if m.inner == nil {
+		return 0, io.EOF
+	}
+	if err := m.ctx.Err(); err != nil {
+		return 0, err
+	}
 	n, err := m.inner.Read(xs)
-	if err != nil {
-		if err == io.EOF {
-			err = m.closeAndOpen()
-			if err == nil {
-				return m.Read(xs)
-			}
+	if err == io.EOF {
+		// decompressors can give the last bytes of a file with io.EOF
+		if n > 0 {
+			return n, nil
+		}
+		err = m.closeAndOpen()
+		if err == nil {
+			return m.Read(xs)
 		}
 	}
 	return n, err
 }
 
+// Close stops the goroutine walking the files and closes the current file.
 func (m *multiReader) Close() error {
-	return m.inner.Close()
+	m.cancel()
+	var err error
+	if m.inner != nil {
+		err = m.inner.Close()
+		m.inner = nil
+	}
+	if m.bundle != nil {
+		if e := m.bundle.Close(); e != nil {
+			err = e
+		}
+		m.bundle = nil
+	}
+	return err
 }
 
-func (m *multiReader) openFile() (*os.File, error) {
-	f, ok := <-m.files
-	if !ok {
-		return nil, io.EOF
+// openEntry opens the next file sent by the goroutine walking an archive,
+// skipping the files that can not be opened if the config allows it.
+func openEntry(ctx context.Context, files <-chan entry, cfg browseConfig) (*File, error) {
+	for {
+		var (
+			e  entry
+			ok bool
+		)
+		select {
+		case e, ok = <-files:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if !ok {
+			return nil, io.EOF
+		}
+		err := e.err
+		if err == nil {
+			var f *File
+			if f, err = OpenFS(cfg.fsys, e.file); err == nil {
+				if err = cfg.seek(f); err == nil {
+					if e.partial && cfg.filter != nil {
+						f.filterFrames(cfg.filter)
+					}
+					return f, nil
+				}
+				f.Close()
+				if err == io.EOF {
+					continue
+				}
+			}
+		}
+		if cfg.skip == nil {
+			return nil, err
+		}
+		cfg.skip(e.file, err)
+	}
+}
+
+// entry is a file found by the goroutine walking an archive or the error
+// encountered while walking it.
+type entry struct {
+	file    string
+	err     error
+	partial bool
+}
+
+func send(ctx context.Context, q chan<- entry, e entry) bool {
+	select {
+	case q <- e:
+		return true
+	case <-ctx.Done():
+		return false
 	}
-	return os.Open(f)
 }
 
-func walk(files []string, recurse bool) <-chan string {
-	q := make(chan string)
+// walk sends the .dat files found in the given files and directories.
+func walk(ctx context.Context, files []string, recurse bool, cfg browseConfig) <-chan entry {
+	q := make(chan entry)
 	go func() {
 		defer close(q)
-		for i := 0; i < len(files); i++ {
-			filepath.Walk(files[i], func(p string, i os.FileInfo, err error) error {
+		c := collector{ctx: ctx, q: q, cfg: cfg}
+		for _, root := range files {
+			walkDir(cfg.fsys, root, func(p string, i fs.DirEntry, err error) error {
+				if e := ctx.Err(); e != nil {
+					return e
+				}
 				if err != nil {
-					return err
+					return c.add(entry{file: p, err: err})
 				}
 				if i.IsDir() {
-					if !recurse {
-						return filepath.SkipDir
+					if !recurse && p != root {
+						return fs.SkipDir
 					} else {
 						return nil
 					}
 				}
-				if e := filepath.Ext(p); e == ".dat" {
-					q <- p
+				if hasExt(p, ".dat") {
+					return c.add(entry{file: p})
 				}
 				return nil
 			})
+			if ctx.Err() != nil {
+				return
+			}
 		}
+		c.flush()
 	}()
 	return q
 }
 
+// collector gathers the files found while walking an archive. Unless the
+// files are ordered, they are sent as soon as they are found; otherwise they
+// are sent, sorted, by flush once the walk is done.
+type collector struct {
+	ctx  context.Context
+	q    chan<- entry
+	cfg  browseConfig
+	list []entry
+	errs []entry
+}
+
+func (c *collector) add(e entry) error {
+	switch {
+	case !c.cfg.ordered:
+		if !send(c.ctx, c.q, e) {
+			return c.ctx.Err()
+		}
+	case e.err != nil:
+		c.errs = append(c.errs, e)
+	default:
+		c.list = append(c.list, e)
+	}
+	return nil
+}
+
+func (c *collector) flush() {
+	c.cfg.sort(c.list)
+	for _, e := range append(c.errs, c.list...) {
+		if !send(c.ctx, c.q, e) {
+			return
+		}
+	}
+}
+
+// walkDir walks the file tree rooted at root in fsys, or in the OS filesystem
+// if fsys is nil.
+func walkDir(fsys fs.FS, root string, fn fs.WalkDirFunc) error {
+	if fsys == nil {
+		return filepath.WalkDir(root, fn)
+	}
+	return fs.WalkDir(fsys, root, fn)
+}
+
 type writer struct {
 	io.Writer
 }
@@ -378,13 +778,22 @@ type splitWriter struct {
 }
 
 func Split(dir string, n int) (io.WriteCloser, error) {
+	return SplitCompress(dir, n, "", 0)
+}
+
+// SplitCompress is like Split but compresses the files with the codec
+// registered for ext.
+func SplitCompress(dir string, n int, ext string, level int) (io.WriteCloser, error) {
+	if _, ok := compressor(ext); ext != "" && !ok {
+		return nil, fmt.Errorf("split: no codec registered for %s", ext)
+	}
 	if n <= 1 {
 		n = 2
 	}
 	ws := make([]io.Writer, n)
 	cs := make([]io.Closer, n)
 	for i := 0; i < n; i++ {
-		w, err := os.Create(filepath.Join(dir, fmt.Sprintf("rt_%04d.dat", i+1)))
+		w, err := Create(filepath.Join(dir, fmt.Sprintf("rt_%04d.dat%s", i+1, ext)), level)
 		if err != nil {
 			return nil, err
 		}
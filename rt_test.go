@@ -0,0 +1,115 @@
+package rt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestReaderResync(t *testing.T) {
+	frame := func(p string) []byte {
+		bs := make([]byte, 4, 4+len(p))
+		binary.LittleEndian.PutUint32(bs, uint32(len(p)))
+		return append(bs, p...)
+	}
+	corrupt := func(bs []byte, size int) []byte {
+		binary.LittleEndian.PutUint32(bs, uint32(size))
+		return bs
+	}
+	join := func(bs ...[]byte) []byte {
+		return bytes.Join(bs, nil)
+	}
+	data := []struct {
+		Name    string
+		Input   []byte
+		Check   MatchFunc
+		Want    []string
+		Skipped int64
+		Err     error
+	}{
+		{
+			Name:  "valid",
+			Input: join(frame("alpha"), frame("beta"), frame("gamma")),
+			Want:  []string{"alpha", "beta", "gamma"},
+			Err:   io.EOF,
+		},
+		{
+			Name:    "corrupted-length",
+			Input:   join(frame("alpha"), corrupt(frame("betabetabeta"), 3), frame("gamma")),
+			Want:    []string{"alpha", "gamma"},
+			Skipped: 16,
+			Err:     io.EOF,
+		},
+		{
+			Name:    "garbage-before",
+			Input:   join([]byte{0xff, 0xff}, frame("alpha"), frame("beta")),
+			Want:    []string{"alpha", "beta"},
+			Skipped: 2,
+			Err:     io.EOF,
+		},
+		{
+			// alpha is not followed by a length prefix and is dropped too
+			Name:    "garbage-between",
+			Input:   join(frame("alpha"), []byte{0xff, 0xff, 0xff, 0xff, 0xfe}, frame("beta"), frame("gamma")),
+			Want:    []string{"beta", "gamma"},
+			Skipped: 14,
+			Err:     io.EOF,
+		},
+		{
+			Name:    "check",
+			Input:   join(frame("alpha"), frame("xray"), frame("gamma")),
+			Check:   func(bs []byte) bool { return bs[0] != 'x' },
+			Want:    []string{"alpha", "gamma"},
+			Skipped: 8,
+			Err:     io.EOF,
+		},
+		{
+			Name:  "truncated-tail",
+			Input: join(frame("alpha"), frame("beta")[:6]),
+			Want:  []string{"alpha"},
+			Err:   TruncatedError(2),
+		},
+		{
+			Name:    "truncated-tail-after-garbage",
+			Input:   join(frame("alpha"), []byte{0xff, 0xff, 0xff, 0xff}, frame("beta")[:6]),
+			Skipped: 19,
+			Err:     io.EOF,
+		},
+	}
+	for _, d := range data {
+		t.Run(d.Name, func(t *testing.T) {
+			var (
+				skipped int64
+				got     []string
+				err     error
+				buf     = make([]byte, 64)
+			)
+			options := []ReaderOption{
+				WithResync(0),
+				OnSkip(func(n int) { skipped += int64(n) }),
+			}
+			if d.Check != nil {
+				options = append(options, WithCheck(d.Check))
+			}
+			r := NewReader(bytes.NewReader(d.Input), options...)
+			for {
+				var n int
+				if n, err = r.Read(buf); err != nil {
+					break
+				}
+				got = append(got, string(buf[4:n]))
+			}
+			if err != d.Err {
+				t.Errorf("error: want %v, got %v", d.Err, err)
+			}
+			if !reflect.DeepEqual(got, d.Want) {
+				t.Errorf("packets: want %q, got %q", d.Want, got)
+			}
+			if r.Skipped() != d.Skipped || skipped != d.Skipped {
+				t.Errorf("skipped: want %d, got %d (reported %d)", d.Skipped, r.Skipped(), skipped)
+			}
+		})
+	}
+}
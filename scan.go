@@ -0,0 +1,166 @@
+package rt
+
+import (
+	"fmt"
+	"io"
+)
+
+const MaxPacketSize = 8 << 20
+
+type Packet struct {
+	Payload []byte
+	File    string
+	Offset  int64
+	Index   int
+}
+
+// Scanner reads packets from an rt stream in the style of bufio.Scanner. When
+// created on the result of Browse, it keeps track of the file each packet
+// comes from; Offset and Index of a Packet are then relative to this file.
+// When created on a File already moved forward (eg: by SeekTime), Offset
+// stays relative to the start of the file.
+//
+// A file of a browsed stream with an invalid length prefix or a truncated last
+// frame is left for the next file. The error is given to the func set with
+// SkipErrors, if any; otherwise Err reports the first of these errors once all
+// the files were read.
+type Scanner struct {
+	files  *multiReader
+	reader *Reader
+	buffer []byte
+	max    int
+
+	file   string
+	index  int
+	packet Packet
+	err    error
+	bad    error
+	done   bool
+}
+
+func NewScanner(r io.Reader, options ...ReaderOption) *Scanner {
+	var s Scanner
+	if m, ok := r.(*multiReader); ok {
+		s.files = m
+		r = m.inner
+	}
+	if f, ok := r.(interface{ Name() string }); ok {
+		s.file = f.Name()
+	}
+	s.reader = NewReader(r, options...)
+	if f, ok := r.(*File); ok {
+		s.reader.pos = f.Offset()
+	}
+	s.reader.grow = true
+	if s.reader.limit <= 0 {
+		s.reader.limit = MaxPacketSize
+	}
+	s.max = s.reader.limit
+	return &s
+}
+
+// Buffer sets the initial buffer used by the Scanner and the maximum size of
+// the payload of a packet.
+func (s *Scanner) Buffer(buf []byte, max int) {
+	s.buffer = buf[:cap(buf)]
+	if max > 0 {
+		s.max = max
+		s.reader.limit = max
+	}
+}
+
+func (s *Scanner) Scan() bool {
+	if s.done {
+		return false
+	}
+	if len(s.buffer) < 4 {
+		s.buffer = make([]byte, 4096)
+	}
+	for {
+		n, err := s.reader.Read(s.buffer)
+		switch err {
+		case nil:
+			s.packet = Packet{
+				Payload: s.buffer[4:n],
+				File:    s.file,
+				Offset:  s.offset(s.reader.pos - int64(n)),
+				Index:   s.index,
+			}
+			s.index++
+			return true
+		case io.ErrShortBuffer:
+			size := len(s.buffer) * 2
+			for size < s.reader.needed {
+				size *= 2
+			}
+			if max := s.max + 4; size > max {
+				size = max
+			}
+			s.buffer = make([]byte, size)
+		case io.EOF:
+			if s.files == nil {
+				return s.stop(nil)
+			}
+			if !s.next() {
+				return false
+			}
+		default:
+			if s.files == nil || !corrupted(err) {
+				return s.stop(err)
+			}
+			if s.files.skip != nil {
+				s.files.skip(s.file, err)
+			} else if s.bad == nil {
+				s.bad = fmt.Errorf("%s: %w", s.file, err)
+			}
+			if !s.next() {
+				return false
+			}
+		}
+	}
+}
+
+// next moves the Scanner to the next file of a browsed stream.
+func (s *Scanner) next() bool {
+	if err := s.files.closeAndOpen(); err != nil {
+		if err == io.EOF {
+			err = s.bad
+		}
+		return s.stop(err)
+	}
+	s.file, s.index = s.files.inner.Name(), 0
+	s.reader.Reset(s.files.inner)
+	s.reader.pos = s.files.inner.Offset()
+	return true
+}
+
+// offset gives the position in the current file of the byte at pos of the
+// stream read by the Reader (see BrowseRange for files that drop frames).
+func (s *Scanner) offset(pos int64) int64 {
+	if s.files == nil || s.files.inner == nil {
+		return pos
+	}
+	return s.files.inner.offsetOf(pos)
+}
+
+// corrupted reports whether err comes from the content of a file rather than
+// from reading it.
+func corrupted(err error) bool {
+	if _, ok := err.(TruncatedError); ok {
+		return true
+	}
+	return err == ErrInvalid || err == io.ErrUnexpectedEOF
+}
+
+func (s *Scanner) Packet() Packet {
+	return s.packet
+}
+
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+func (s *Scanner) stop(err error) bool {
+	s.done, s.err = true, err
+	return false
+}
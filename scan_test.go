@@ -0,0 +1,76 @@
+package rt
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScannerSkipCorrupted(t *testing.T) {
+	frame := func(p string) []byte {
+		bs := make([]byte, 4, 4+len(p))
+		binary.LittleEndian.PutUint32(bs, uint32(len(p)))
+		return append(bs, p...)
+	}
+	data := []struct {
+		Name    string
+		Content []byte
+	}{
+		{Name: "a.dat", Content: frame("alpha")},
+		{Name: "b.dat", Content: append(frame("beta"), 0xff, 0xff, 0xff, 0xff, 1, 2)},
+		{Name: "c.dat", Content: append(frame("gamma"), frame("delta")[:6]...)},
+		{Name: "d.dat", Content: frame("epsilon")},
+	}
+	dir := t.TempDir()
+	for _, d := range data {
+		if err := os.WriteFile(filepath.Join(dir, d.Name), d.Content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := Browse([]string{dir}, true, Unordered())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var (
+		sc   = NewScanner(r)
+		got  []string
+		want = []string{"alpha", "beta", "gamma", "epsilon"}
+	)
+	for sc.Scan() {
+		got = append(got, string(sc.Packet().Payload))
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("packet %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+	if err := sc.Err(); !errors.Is(err, ErrInvalid) {
+		t.Errorf("expected ErrInvalid, got %v", err)
+	}
+
+	var skipped []string
+	r, err = Browse([]string{dir}, true, Unordered(), SkipErrors(func(file string, err error) {
+		skipped = append(skipped, filepath.Base(file))
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	sc = NewScanner(r)
+	for sc.Scan() {
+	}
+	if err := sc.Err(); err != nil {
+		t.Errorf("expected no error with SkipErrors, got %v", err)
+	}
+	if len(skipped) != 2 || skipped[0] != "b.dat" || skipped[1] != "c.dat" {
+		t.Errorf("skipped files: got %q, want [b.dat c.dat]", skipped)
+	}
+}
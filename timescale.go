@@ -0,0 +1,209 @@
+package rt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scale identifies the time scale in which the wall clock reading of a
+// time.Time should be interpreted.
+type Scale uint8
+
+const (
+	ScaleUTC Scale = iota
+	ScaleTAI
+	ScaleGPS
+)
+
+// offset between TAI and GPS time, constant since the GPS epoch.
+const taiGPS = 19 * time.Second
+
+func (s Scale) String() string {
+	switch s {
+	case ScaleTAI:
+		return "tai"
+	case ScaleGPS:
+		return "gps"
+	default:
+		return "utc"
+	}
+}
+
+func ParseScale(str string) (Scale, error) {
+	switch strings.ToLower(str) {
+	case "", "utc":
+		return ScaleUTC, nil
+	case "tai":
+		return ScaleTAI, nil
+	case "gps":
+		return ScaleGPS, nil
+	default:
+		return ScaleUTC, fmt.Errorf("unknown time scale %s", str)
+	}
+}
+
+type leap struct {
+	When   time.Time
+	Offset time.Duration
+}
+
+var leaps = struct {
+	sync.RWMutex
+	table []leap
+}{
+	table: defaultLeaps(),
+}
+
+func defaultLeaps() []leap {
+	dates := []struct {
+		year, month, offset int
+	}{
+		{1972, 1, 10}, {1972, 7, 11}, {1973, 1, 12}, {1974, 1, 13},
+		{1975, 1, 14}, {1976, 1, 15}, {1977, 1, 16}, {1978, 1, 17},
+		{1979, 1, 18}, {1980, 1, 19}, {1981, 7, 20}, {1982, 7, 21},
+		{1983, 7, 22}, {1985, 7, 23}, {1988, 1, 24}, {1990, 1, 25},
+		{1991, 1, 26}, {1992, 7, 27}, {1993, 7, 28}, {1994, 7, 29},
+		{1996, 1, 30}, {1997, 7, 31}, {1999, 1, 32}, {2006, 1, 33},
+		{2009, 1, 34}, {2012, 7, 35}, {2015, 7, 36}, {2017, 1, 37},
+	}
+	table := make([]leap, len(dates))
+	for i, d := range dates {
+		table[i] = leap{
+			When:   time.Date(d.year, time.Month(d.month), 1, 0, 0, 0, 0, time.UTC),
+			Offset: time.Duration(d.offset) * time.Second,
+		}
+	}
+	return table
+}
+
+var ntpEpoch = time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// LoadLeapSeconds replaces the built-in leap seconds table by the one found in
+// file. See ReadLeapSeconds for the supported format.
+func LoadLeapSeconds(file string) error {
+	r, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return ReadLeapSeconds(r)
+}
+
+// ReadLeapSeconds replaces the built-in leap seconds table. Each line gives
+// the date at which a new TAI-UTC offset applies and this offset in seconds.
+// The date is either a number of seconds since 1900 (as in the leap-seconds.list
+// published by the IERS) or a date formatted as YYYY-MM-DD. Text after a # is
+// ignored.
+func ReadLeapSeconds(r io.Reader) error {
+	var (
+		table []leap
+		scan  = bufio.NewScanner(r)
+	)
+	for scan.Scan() {
+		line := scan.Text()
+		if ix := strings.IndexByte(line, '#'); ix >= 0 {
+			line = line[:ix]
+		}
+		fs := strings.Fields(line)
+		if len(fs) == 0 {
+			continue
+		}
+		if len(fs) < 2 {
+			return fmt.Errorf("leap seconds: invalid line %q", scan.Text())
+		}
+		var (
+			l   leap
+			err error
+		)
+		if n, e := strconv.ParseInt(fs[0], 10, 64); e == nil {
+			l.When = ntpEpoch.Add(time.Duration(n) * time.Second)
+		} else if l.When, err = time.Parse("2006-01-02", fs[0]); err != nil {
+			return fmt.Errorf("leap seconds: invalid date %s", fs[0])
+		}
+		n, err := strconv.Atoi(fs[1])
+		if err != nil {
+			return fmt.Errorf("leap seconds: invalid offset %s", fs[1])
+		}
+		l.Offset = time.Duration(n) * time.Second
+		table = append(table, l)
+	}
+	if err := scan.Err(); err != nil {
+		return err
+	}
+	if len(table) == 0 {
+		return fmt.Errorf("leap seconds: empty table")
+	}
+	sort.Slice(table, func(i, j int) bool {
+		return table[i].When.Before(table[j].When)
+	})
+
+	leaps.Lock()
+	defer leaps.Unlock()
+	leaps.table = table
+	return nil
+}
+
+// LeapSeconds gives the TAI-UTC offset at the given UTC time.
+func LeapSeconds(t time.Time) time.Duration {
+	leaps.RLock()
+	defer leaps.RUnlock()
+
+	var off time.Duration
+	for _, l := range leaps.table {
+		if t.Before(l.When) {
+			break
+		}
+		off = l.Offset
+	}
+	return off
+}
+
+func utcFromTAI(t time.Time) time.Time {
+	leaps.RLock()
+	defer leaps.RUnlock()
+
+	var off time.Duration
+	for _, l := range leaps.table {
+		if t.Before(l.When.Add(l.Offset)) {
+			break
+		}
+		off = l.Offset
+	}
+	return t.Add(-off)
+}
+
+func (s Scale) FromUTC(t time.Time) time.Time {
+	switch s {
+	case ScaleTAI:
+		return t.Add(LeapSeconds(t))
+	case ScaleGPS:
+		return t.Add(LeapSeconds(t) - taiGPS)
+	default:
+		return t
+	}
+}
+
+func (s Scale) ToUTC(t time.Time) time.Time {
+	switch s {
+	case ScaleTAI:
+		return utcFromTAI(t)
+	case ScaleGPS:
+		return utcFromTAI(t.Add(taiGPS))
+	default:
+		return t
+	}
+}
+
+func Convert(t time.Time, from, to Scale) time.Time {
+	if from == to {
+		return t
+	}
+	return to.FromUTC(from.ToUTC(t))
+}